@@ -0,0 +1,180 @@
+package flagstruct
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// RegisterAllowed registers the allowed values of a flag annotated with
+// ",allowedby=<flag>", keyed by each possible value of the controlling flag.
+//
+//	p.RegisterAllowed("format", map[string][]string{
+//		"text":   {"plain", "markdown"},
+//		"binary": {"protobuf", "msgpack"},
+//	})
+func (p *Parser) RegisterAllowed(name string, sets map[string][]string) {
+	if p.allowedBy == nil {
+		p.allowedBy = make(map[string]map[string][]string)
+	}
+	p.allowedBy[name] = sets
+}
+
+// isAllowed reports whether flagVal is one of the allowed values of the
+// annotation. For integer fields, entries of the form `a-b` allow any value
+// of the inclusive range (e.g. `allowed=1;2;5-10`).
+func (a *annotation) isAllowed(flagVal string) bool {
+	if inSlice(a.allowed, flagVal) {
+		return true
+	}
+	if !a.integer {
+		return false
+	}
+	v, ok := new(big.Int).SetString(flagVal, 10)
+	if !ok {
+		return false
+	}
+	for _, entry := range a.allowed {
+		// the bounds are separated by the first dash not leading the entry,
+		// so negative bounds are supported (e.g. `-10--5`)
+		if len(entry) < 3 {
+			continue
+		}
+		i := strings.Index(entry[1:], "-") + 1
+		if i == 0 {
+			continue
+		}
+		from, okFrom := new(big.Int).SetString(entry[:i], 10)
+		to, okTo := new(big.Int).SetString(entry[i+1:], 10)
+		if okFrom && okTo && v.Cmp(from) >= 0 && v.Cmp(to) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestion returns the hint naming the allowed value closest to flagVal,
+// which is not given for integers.
+func (a *annotation) suggestion(flagVal string) string {
+	if a.integer {
+		return ""
+	}
+	return suggestion(flagVal, a.allowed)
+}
+
+// disallowedElement returns the first element of the list flagVal (or of
+// every occurrence of the flag) which is not allowed, reporting whether any.
+func (a *annotation) disallowedElement(flagVal string) (string, bool) {
+	occurrences := a.occurrences
+	if len(occurrences) < 2 {
+		occurrences = []string{flagVal}
+	}
+	for _, o := range occurrences {
+		for _, e := range splitQuoted(o, a.sep) {
+			if e = unquote(strings.TrimSpace(e)); e != "" && !a.isAllowed(e) {
+				return e, true
+			}
+		}
+	}
+	return "", false
+}
+
+// disallowedEntries returns the sorted keys of the map entries of flagVal
+// (or of every occurrence of the flag) whose value is not allowed, where the
+// keys themselves are checked for entries without a value (e.g. sets), along
+// with the offending value of the first key. The elements of slices of maps
+// hold entries separated by the inner separator.
+func (a *annotation) disallowedEntries(flagVal string) ([]string, string) {
+	occurrences := a.occurrences
+	if len(occurrences) < 2 {
+		occurrences = []string{flagVal}
+	}
+	var maps []string
+	for _, o := range occurrences {
+		if !a.list {
+			maps = append(maps, o)
+			continue
+		}
+		for _, e := range splitQuoted(o, a.sep) {
+			maps = append(maps, unquote(strings.TrimSpace(e)))
+		}
+	}
+	sep := a.sep
+	if a.list {
+		sep = a.innerSep
+	}
+	offending := make(map[string]string)
+	for _, m := range maps {
+		entries := make(map[string]string)
+		for _, x := range strings.Split(m, sep) {
+			if x = strings.TrimSpace(x); x == "" {
+				continue
+			}
+			kv := strings.SplitN(x, ":", 2)
+			key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[0])
+			if len(kv) == 2 {
+				value = strings.TrimSpace(kv[1])
+			}
+			entries[key] = value
+		}
+		for key, value := range entries {
+			if _, ok := offending[key]; !ok && !a.isAllowed(value) {
+				offending[key] = value
+			}
+		}
+	}
+	keys := make([]string, 0, len(offending))
+	for key := range offending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		return nil, ""
+	}
+	return keys, offending[keys[0]]
+}
+
+// checkAllowedBy validates the flags whose allowed values depend on another
+// flag, once every flag of the decoding process has been resolved.
+func (p *Parser) checkAllowedBy(s *state) error {
+	for _, a := range s.controlled {
+		flagVal := s.values[a.name]
+		if flagVal == "" {
+			continue
+		}
+		mode := s.values[a.allowedBy]
+		allowed := p.allowedBy[a.name][mode]
+		if !inSlice(allowed, flagVal) {
+			return fmt.Errorf(
+				"flagstruct: the value `%s` of flag '%s' is not allowed when '%s' is `%s`, instead use %+v%s",
+				flagVal, a.name, a.allowedBy, mode, allowed, suggestion(flagVal, allowed),
+			)
+		}
+	}
+	return nil
+}
+
+// allowedValues returns the newline separated allowed values of the given
+// file, which is read once per decoding process.
+func (s *state) allowedValues(path string) ([]string, error) {
+	if values, ok := s.allowedFiles[path]; ok {
+		return values, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			values = append(values, line)
+		}
+	}
+	if s.allowedFiles == nil {
+		s.allowedFiles = make(map[string][]string)
+	}
+	s.allowedFiles[path] = values
+	return values, nil
+}
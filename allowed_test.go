@@ -0,0 +1,228 @@
+package flagstruct
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRegisterAllowed(t *testing.T) {
+	type test struct {
+		Mode   string `flag:"mode,allowed=text;binary,default=text"`
+		Format string `flag:"format,allowedby=mode"`
+	}
+
+	type decode struct {
+		args []string
+		err  string
+	}
+	tests := []*decode{
+		{args: []string{"-format=markdown"}},
+		{args: []string{"-mode=binary", "-format=msgpack"}},
+		{args: []string{"-mode=binary"}},
+		{
+			args: []string{"-mode=binary", "-format=markdown"},
+			err:  "flagstruct: the value `markdown` of flag 'format' is not allowed when 'mode' is `binary`, instead use [protobuf msgpack]",
+		},
+		{
+			args: []string{"-format=msgpack"},
+			err:  "flagstruct: the value `msgpack` of flag 'format' is not allowed when 'mode' is `text`, instead use [plain markdown]",
+		},
+	}
+
+	p := Parser{}
+	p.RegisterAllowed("format", map[string][]string{
+		"text":   {"plain", "markdown"},
+		"binary": {"protobuf", "msgpack"},
+	})
+	for i, ts := range tests {
+		var result test
+		p.Args = ts.args
+		err := p.Decode(&result)
+		if (ts.err == "" && err != nil) || (ts.err != "" && (err == nil || err.Error() != ts.err)) {
+			t.Errorf("case #%d: wrong error expected %s got %v", i, ts.err, err)
+		}
+	}
+}
+
+func TestAllowedRanges(t *testing.T) {
+	type test struct {
+		Port    int    `flag:"port,allowed=80;443;8000-8999"`
+		Offset  int8   `flag:"offset,allowed=-10--5;0;5-10"`
+		Workers uint   `flag:"workers,allowed=1-4"`
+		Name    string `flag:"name,allowed=a;b-c"`
+	}
+
+	type decode struct {
+		arg string
+		err bool
+	}
+	tests := []*decode{
+		{arg: "-port=80"},
+		{arg: "-port=443"},
+		{arg: "-port=8000"},
+		{arg: "-port=8500"},
+		{arg: "-port=8999"},
+		{arg: "-port=9000", err: true},
+		{arg: "-port=81", err: true},
+		{arg: "-port=0x1f90", err: true},
+		{arg: "-offset=-7"},
+		{arg: "-offset=-10"},
+		{arg: "-offset=0"},
+		{arg: "-offset=7"},
+		{arg: "-offset=-4", err: true},
+		{arg: "-offset=4", err: true},
+		{arg: "-workers=3"},
+		{arg: "-workers=5", err: true},
+		{arg: "-name=b-c"},
+		{arg: "-name=b", err: true},
+	}
+
+	for i, ts := range tests {
+		var result test
+		err := (&Parser{Args: []string{ts.arg}}).Decode(&result)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result for %s: %v", i, ts.arg, err)
+		}
+	}
+}
+
+func TestAllowedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagstruct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := ioutil.WriteFile("regions", []byte("us-east-1\n eu-west-1 \n\nap-south-1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	type test struct {
+		Region string `flag:"region,allowed-file=regions"`
+		Backup string `flag:"backup,allowed-file=regions"`
+	}
+
+	var ts test
+	p := Parser{Args: []string{"-region=eu-west-1", "-backup=ap-south-1"}}
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if expected := (test{Region: "eu-west-1", Backup: "ap-south-1"}); ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	p.Args = []string{"-region=sa-east-1"}
+	expected := "flagstruct: the provided value is not allowed, instead use [us-east-1 eu-west-1 ap-south-1] (did you mean 'us-east-1'?)"
+	if err := p.Decode(&ts); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected %s got %v", expected, err)
+	}
+
+	s := &state{}
+	if _, err := s.allowedValues("regions"); err != nil {
+		t.Fatalf("unexpected error reading the allowed values: %v", err)
+	}
+	if err := os.Remove("regions"); err != nil {
+		t.Fatal(err)
+	}
+	values, err := s.allowedValues("regions")
+	if err != nil || !reflect.DeepEqual(values, []string{"us-east-1", "eu-west-1", "ap-south-1"}) {
+		t.Errorf("expected the allowed values to be cached, got %v (%v)", values, err)
+	}
+
+	expected = "flagstruct: could not read the allowed values of flag 'region': open regions: no such file or directory"
+	if err := p.Decode(&ts); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected %s got %v", expected, err)
+	}
+}
+
+func TestAllowedElements(t *testing.T) {
+	type test struct {
+		args     []string
+		roles    []string
+		ports    []int
+		expected string
+	}
+
+	tests := []*test{
+		{args: []string{"-roles=admin;guest", "-ports=80;8080"}, roles: []string{"admin", "guest"}, ports: []int{80, 8080}},
+		{args: []string{"-roles= user ; admin", "-roles=guest"}, roles: []string{"user", "admin", "guest"}},
+		{args: []string{"-roles=admin;root;nobody"}, expected: "flagstruct: the element `root` of flag 'roles' is not allowed, instead use [admin user guest]"},
+		{args: []string{"-roles=admin", "-roles=root"}, expected: "flagstruct: the element `root` of flag 'roles' is not allowed, instead use [admin user guest]"},
+		{args: []string{"-ports=443;22"}, expected: "flagstruct: the element `22` of flag 'ports' is not allowed, instead use [80 443 8000-8999]"},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			Roles []string `flag:"roles,allowed=admin;user;guest"`
+			Ports []int    `flag:"ports,allowed=80;443;8000-8999"`
+		}
+		err := (&Parser{Args: ts.args}).Decode(&s)
+		if ts.expected != "" {
+			if err == nil || err.Error() != ts.expected {
+				t.Errorf("case #%d: wrong error expected `%s` got `%v`", i, ts.expected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if !reflect.DeepEqual(s.Roles, ts.roles) || !reflect.DeepEqual(s.Ports, ts.ports) {
+			t.Errorf("case #%d: wrong values got %v and %v", i, s.Roles, s.Ports)
+		}
+	}
+}
+
+func TestAllowedEntries(t *testing.T) {
+	type test struct {
+		args     []string
+		levels   map[string]string
+		expected string
+	}
+
+	tests := []*test{
+		{args: []string{"-levels=db:info;http:debug"}, levels: map[string]string{"db": "info", "http": "debug"}},
+		{args: []string{"-levels=db:info;http:trace", "-features=b;a"}, expected: "flagstruct: the entries of keys [http] of flag 'levels' are not allowed, instead use [debug info warning]"},
+		{args: []string{"-levels=zeta:x;db:info;alpha:y;mid:z;beta:w"}, expected: "flagstruct: the entries of keys [alpha beta mid zeta] of flag 'levels' are not allowed, instead use [debug info warning]"},
+		{args: []string{"-features=c;b;x;a;y"}, expected: "flagstruct: the entries of keys [x y] of flag 'features' are not allowed, instead use [a b c]"},
+		{args: []string{"-weights=a:1;b:42;c:7"}, expected: "flagstruct: the entries of keys [b] of flag 'weights' are not allowed, instead use [1-10]"},
+		{args: []string{"-levels=db:inof;http:debug"}, expected: "flagstruct: the entries of keys [db] of flag 'levels' are not allowed, instead use [debug info warning] (did you mean 'info'?)"},
+		{args: []string{"-rows=db:info,http:debug;db:debug"}},
+		{args: []string{"-levels=db:info", "-levels=http:debug;db:warning"}, levels: map[string]string{"db": "warning", "http": "debug"}},
+		{args: []string{"-rows=db:info;db:trace,http:x"}, expected: "flagstruct: the entries of keys [db http] of flag 'rows' are not allowed, instead use [debug info warning]"},
+	}
+
+	for i, ts := range tests {
+		// repeated to make sure the error does not depend on map iteration
+		for n := 0; n < 20; n++ {
+			var s struct {
+				Levels   map[string]string   `flag:"levels,allowed=debug;info;warning"`
+				Features map[string]struct{} `flag:"features,allowed=a;b;c"`
+				Weights  map[string]int      `flag:"weights,allowed=1-10"`
+				Rows     []map[string]string `flag:"rows,allowed=debug;info;warning"`
+			}
+			err := (&Parser{Args: ts.args}).Decode(&s)
+			if ts.expected != "" {
+				if err == nil || err.Error() != ts.expected {
+					t.Fatalf("case #%d: wrong error expected `%s` got `%v`", i, ts.expected, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("case #%d: unexpected error %v", i, err)
+			}
+			if !reflect.DeepEqual(s.Levels, ts.levels) {
+				t.Fatalf("case #%d: wrong values got %v", i, s.Levels)
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package flagstruct
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RegisterBoolWords registers the words decoded as true and false by boolean
+// fields, compared case-insensitively, before falling back to the values
+// accepted by strconv.ParseBool. It may be called once per locale.
+//
+//	p.RegisterBoolWords([]string{"si", "sí"}, []string{"no"})
+//	p.RegisterBoolWords([]string{"oui"}, []string{"non"})
+func (p *Parser) RegisterBoolWords(truthy, falsy []string) {
+	if p.boolWords == nil {
+		p.boolWords = make(map[string]bool)
+	}
+	for _, w := range truthy {
+		p.boolWords[strings.ToLower(w)] = true
+	}
+	for _, w := range falsy {
+		p.boolWords[strings.ToLower(w)] = false
+	}
+}
+
+// parseBool returns the boolean value of flagVal, which may be any of the
+// registered words.
+func (a *annotation) parseBool(flagVal string) (bool, error) {
+	if v, ok := a.boolWords[strings.ToLower(strings.TrimSpace(flagVal))]; ok {
+		return v, nil
+	}
+	return strconv.ParseBool(flagVal)
+}
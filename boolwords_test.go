@@ -0,0 +1,49 @@
+package flagstruct
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBoolWords(t *testing.T) {
+	type test struct {
+		args     []string
+		verbose  bool
+		color    *bool
+		flags    []bool
+		expected string
+	}
+
+	yes, no := true, false
+	tests := []*test{
+		{args: []string{"-verbose=si", "-color=No"}, verbose: true, color: &no},
+		{args: []string{"-verbose=OUI", "-color=sí"}, verbose: true, color: &yes},
+		{args: []string{"-verbose=non", "-flags=oui;no;true;0"}, flags: []bool{true, false, true, false}},
+		{args: []string{"-verbose=true", "-color=false"}, verbose: true, color: &no},
+		{args: []string{"-verbose=ja"}, expected: "flagstruct: could not decode value `ja` to kind `bool`: strconv.ParseBool: parsing \"ja\": invalid syntax"},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			Verbose bool   `flag:"verbose"`
+			Color   *bool  `flag:"color"`
+			Flags   []bool `flag:"flags"`
+		}
+		p := Parser{Args: ts.args}
+		p.RegisterBoolWords([]string{"si", "sí"}, []string{"no"})
+		p.RegisterBoolWords([]string{"oui"}, []string{"non"})
+		err := p.Decode(&s)
+		if ts.expected != "" {
+			if err == nil || err.Error() != ts.expected {
+				t.Errorf("case #%d: wrong error expected `%s` got `%v`", i, ts.expected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if s.Verbose != ts.verbose || !reflect.DeepEqual(s.Color, ts.color) || !reflect.DeepEqual(s.Flags, ts.flags) {
+			t.Errorf("case #%d: wrong values got %v, %v and %v", i, s.Verbose, s.Color, s.Flags)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package flagstruct
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ContextDecoder is the interface implemented by an object that can decode
+// a command line argument string representation of itself, honoring the
+// cancellation of the given context (e.g. when resolving a value over the
+// network).
+type ContextDecoder interface {
+	DecodeContext(ctx context.Context, value string) error
+}
+
+// decodeContext calls the DecodeContext method of decoder, bounded by the
+// timeout of the annotation when provided.
+func decodeContext(decoder ContextDecoder, flagVal string, a *annotation) error {
+	ctx := context.Background()
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+	err := decoder.DecodeContext(ctx, flagVal)
+	if ctx.Err() == context.DeadlineExceeded {
+		return &timeoutError{name: a.name, timeout: a.timeout}
+	}
+	return err
+}
+
+// timeoutError is returned when a ContextDecoder exceeds the timeout of its
+// annotation.
+type timeoutError struct {
+	name    string
+	timeout time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("flagstruct: decoding flag '%s' timed out after %v", e.name, e.timeout)
+}
@@ -0,0 +1,51 @@
+package flagstruct
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type secretRef struct {
+	value string
+	delay time.Duration
+}
+
+func (s *secretRef) DecodeContext(ctx context.Context, value string) error {
+	select {
+	case <-time.After(s.delay):
+		s.value = "secret-of-" + value
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestContextDecoder(t *testing.T) {
+	type test struct {
+		Fast secretRef `flag:"fast,timeout=1s"`
+		Slow secretRef `flag:"slow,timeout=10ms"`
+		Free secretRef `flag:"free"`
+	}
+	ts := test{Slow: secretRef{delay: time.Second}}
+
+	if err := (&Parser{Args: []string{"-fast=a", "-free=b"}}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if ts.Fast.value != "secret-of-a" || ts.Free.value != "secret-of-b" {
+		t.Errorf("wrong context decoder assignment got %+v", ts)
+	}
+
+	err := (&Parser{Args: []string{"-slow=c"}}).Decode(&ts)
+	if err == nil || !strings.Contains(err.Error(), "flag 'slow' timed out after 10ms") {
+		t.Errorf("expected timeout error naming the flag, got %v", err)
+	}
+	if ts.Slow.value != "" {
+		t.Errorf("expected slow decoder to be canceled, got %s", ts.Slow.value)
+	}
+
+	if _, err := parseAnnotation("slow,timeout=a"); err == nil {
+		t.Error("expected error for a malformed timeout")
+	}
+}
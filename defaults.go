@@ -0,0 +1,142 @@
+package flagstruct
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultTokens holds the dynamic values usable in defaults, resolved at
+// decode time (e.g. `default=$hostname`).
+var defaultTokens = map[string]func() string{
+	"hostname": func() string {
+		name, _ := os.Hostname()
+		return name
+	},
+	"pid": func() string {
+		return strconv.Itoa(os.Getpid())
+	},
+	"user": func() string {
+		if u, err := user.Current(); err == nil {
+			return u.Username
+		}
+		return os.Getenv("USER")
+	},
+	"home": func() string {
+		home, _ := os.UserHomeDir()
+		return home
+	},
+}
+
+// expandDefault expands the references to environment variables and to the
+// dynamic tokens (`$hostname`, `$pid`, `$user` and `$home`) of the default
+// value, where `$$` stands for a literal `$`.
+func expandDefault(value string) string {
+	return os.Expand(value, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		if token, ok := defaultTokens[name]; ok {
+			return token()
+		}
+		return os.Getenv(name)
+	})
+}
+
+// defaultsFiles holds the cached values of every defaults file, keyed by its
+// path, shared by every parser (and their copies, like the ones made by
+// DecodeStream for each batch).
+var defaultsFiles sync.Map
+
+// defaultsFile caches the values of Parser.DefaultsFile, along with the
+// state of the file when it was read.
+type defaultsFile struct {
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	values  MapSource
+}
+
+// fileDefaults returns the values of the defaults file, reading it again
+// when it changed since the previous decoding process.
+func (p *Parser) fileDefaults() (MapSource, error) {
+	if p.DefaultsFile == "" {
+		return nil, nil
+	}
+	v, _ := defaultsFiles.LoadOrStore(p.DefaultsFile, &defaultsFile{})
+	cache := v.(*defaultsFile)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	info, err := os.Stat(p.DefaultsFile)
+	if err != nil {
+		return nil, fmt.Errorf("flagstruct: could not read the defaults file: %v", err)
+	}
+	if cache.values != nil && cache.modTime.Equal(info.ModTime()) && cache.size == info.Size() {
+		return cache.values, nil
+	}
+	values, err := FileSource(p.DefaultsFile)
+	if err != nil {
+		return nil, fmt.Errorf("flagstruct: could not read the defaults file: %v", err)
+	}
+	cache.modTime, cache.size, cache.values = info.ModTime(), info.Size(), values
+	return values, nil
+}
+
+// RegisterDefaultFunc registers the function computing the default value of
+// the field (the Go field name) of the struct type t, once every other flag
+// of the struct was decoded. The function receives a pointer to the struct,
+// and is only called when the field is still zero and no required flag is
+// missing. Since computed values are not zero, later decodes in Merge mode
+// (like the batches of DecodeStream) keep them, even when the fields they
+// were computed from change.
+//
+//	p.RegisterDefaultFunc(reflect.TypeOf(User{}), "DisplayName", func(v interface{}) string {
+//		u := v.(*User)
+//		return u.First + " " + u.Last
+//	})
+func (p *Parser) RegisterDefaultFunc(t reflect.Type, field string, fn func(v interface{}) string) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if p.defaultFuncs == nil {
+		p.defaultFuncs = make(map[reflect.Type]map[string]func(v interface{}) string)
+	}
+	if p.defaultFuncs[t] == nil {
+		p.defaultFuncs[t] = make(map[string]func(v interface{}) string)
+	}
+	p.defaultFuncs[t][field] = fn
+}
+
+// computeDefaults decodes the values computed by the default functions into
+// the zero fields of vl they were registered for.
+func (p *Parser) computeDefaults(vl reflect.Value, prefix string) error {
+	funcs := p.defaultFuncs[vl.Type()]
+	for i := 0; i < vl.NumField() && len(funcs) != 0; i++ {
+		ft := vl.Type().Field(i)
+		fn, ok := funcs[ft.Name]
+		f := vl.Field(i)
+		if !ok || !f.CanSet() || !isZero(f) {
+			continue
+		}
+		value := fn(vl.Addr().Interface())
+		if value == "" {
+			continue
+		}
+		a := newAnnotation(ft.Name)
+		if tag := p.tag(ft, true); tag != "" {
+			var err error
+			if a, err = parseAnnotation(tag); err != nil {
+				return err
+			}
+			a.name = prefix + a.name
+		}
+		if err := p.decodeField(&f, value, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package flagstruct
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDefaultTokens(t *testing.T) {
+	defer unsetenv("NODE_REGION")
+	setenv(t, "NODE_REGION", "eu")
+
+	var s struct {
+		Node   string `flag:"node-id,default=$hostname"`
+		PID    int    `flag:"pid,default=$pid"`
+		User   string `flag:"user,default=${user}"`
+		Home   string `flag:"home,default=$home/.app"`
+		Region string `flag:"region,default=$NODE_REGION-$hostname"`
+		Price  string `flag:"price,default=$$5"`
+	}
+
+	if err := (&Parser{Args: []string{}}).Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	hostname, _ := os.Hostname()
+	home, _ := os.UserHomeDir()
+	username := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	if s.Node != hostname || s.Node == "" {
+		t.Errorf("wrong node expected %q got %q", hostname, s.Node)
+	}
+	if s.PID != os.Getpid() {
+		t.Errorf("wrong pid expected %d got %d", os.Getpid(), s.PID)
+	}
+	if s.User != username {
+		t.Errorf("wrong user expected %q got %q", username, s.User)
+	}
+	if s.Home != home+"/.app" {
+		t.Errorf("wrong home expected %q got %q", home+"/.app", s.Home)
+	}
+	if s.Region != "eu-"+hostname {
+		t.Errorf("wrong region expected %q got %q", "eu-"+hostname, s.Region)
+	}
+	if s.Price != "$5" {
+		t.Errorf("wrong price expected %q got %q", "$5", s.Price)
+	}
+
+	s.Node = ""
+	if err := (&Parser{Args: []string{"-node-id=node-1"}}).Decode(&s); err != nil || s.Node != "node-1" {
+		t.Errorf("expected the provided value to take precedence, got %q (%v)", s.Node, err)
+	}
+}
+
+func TestDefaultsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagstruct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "defaults.conf")
+	write := func(content string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type config struct {
+		Host  string `flag:"host,default=localhost"`
+		Port  int    `flag:"port,default=80"`
+		Level string `flag:"level,default=info"`
+	}
+
+	then := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write("host=a.example.com\nport=8080\n", then)
+	p := Parser{Args: []string{"-level=debug"}, DefaultsFile: path}
+
+	var c config
+	if err := p.Decode(&c); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if expected := (config{Host: "a.example.com", Port: 8080, Level: "debug"}); c != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, c)
+	}
+
+	// the same modification time and size keep the cached defaults
+	write("host=b.example.com\nport=8080\n", then)
+	if err := p.Decode(&c); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if c.Host != "a.example.com" {
+		t.Errorf("expected the cached defaults, got host %s", c.Host)
+	}
+
+	write("host=b.example.com\n", then.Add(time.Minute))
+	c = config{}
+	if err := p.Decode(&c); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if expected := (config{Host: "b.example.com", Port: 80, Level: "debug"}); c != expected {
+		t.Errorf("wrong assignment after the change expected %+v got %+v", expected, c)
+	}
+
+	// the cache outlives the copies of the parser made for every batch
+	write("host=c.example.com\n", then)
+	ch := make(chan []string)
+	done := make(chan struct{})
+	c = config{}
+	go func() {
+		p.DecodeStream(&c, ch, func(err error) { t.Errorf("unexpected error %v", err) })
+		close(done)
+	}()
+	ch <- []string{"-level=warn"}
+	// receiving the next batch means the previous one was applied, while
+	// the file is replaced atomically, as the next one may be reading it
+	ch <- []string{}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte("host=d.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(tmp, then, then); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	ch <- []string{"-port=9090"}
+	close(ch)
+	<-done
+	if expected := (config{Host: "c.example.com", Port: 9090, Level: "warn"}); c != expected {
+		t.Errorf("wrong assignment while streaming expected %+v got %+v", expected, c)
+	}
+
+	os.Remove(path)
+	if err := p.Decode(&c); err == nil {
+		t.Error("expected an error with a missing defaults file")
+	}
+}
+
+type person struct {
+	First       string `flag:"first"`
+	Last        string `flag:"last"`
+	DisplayName string `flag:"display-name"`
+	Initials    []string
+}
+
+type team struct {
+	Name        string `flag:"name"`
+	DisplayName string `flag:"display-name"`
+}
+
+func TestDefaultFunc(t *testing.T) {
+	var s struct {
+		Owner person `flag:",prefix=owner."`
+		Team  team   `flag:",prefix=team."`
+		Age   int    `flag:"age"`
+	}
+
+	p := Parser{Args: []string{"-owner.first=Ada", "-owner.last=Lovelace", "-team.name=engines"}}
+	p.RegisterDefaultFunc(reflect.TypeOf(person{}), "DisplayName", func(v interface{}) string {
+		u := v.(*person)
+		return u.First + " " + u.Last
+	})
+	p.RegisterDefaultFunc(reflect.TypeOf(&person{}), "Initials", func(v interface{}) string {
+		u := v.(*person)
+		return u.First[:1] + ";" + u.Last[:1]
+	})
+	p.RegisterDefaultFunc(reflect.TypeOf(team{}), "DisplayName", func(v interface{}) string {
+		return "Team " + v.(*team).Name
+	})
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if s.Owner.DisplayName != "Ada Lovelace" || !reflect.DeepEqual(s.Owner.Initials, []string{"A", "L"}) {
+		t.Errorf("wrong computed defaults %+v", s.Owner)
+	}
+	if s.Team.DisplayName != "Team engines" {
+		t.Errorf("wrong computed default of another struct %+v", s.Team)
+	}
+
+	s.Owner = person{}
+	p.Args = []string{"-owner.first=Ada", "-owner.last=Lovelace", "-owner.display-name=Countess"}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if s.Owner.DisplayName != "Countess" {
+		t.Errorf("expected the provided value to take precedence, got %q", s.Owner.DisplayName)
+	}
+
+	p.RegisterDefaultFunc(reflect.TypeOf(s), "Age", func(v interface{}) string { return "unknown" })
+	if err := p.Decode(&s); err == nil {
+		t.Error("expected an error with an invalid computed default")
+	}
+
+	var r struct {
+		Token string `flag:"token,required"`
+		Label string `flag:"label"`
+	}
+	called := false
+	q := Parser{Args: []string{}}
+	q.RegisterDefaultFunc(reflect.TypeOf(r), "Label", func(v interface{}) string {
+		called = true
+		return "label"
+	})
+	if err := q.Decode(&r); err == nil || called {
+		t.Errorf("expected the function not to be called with missing flags, got %v", err)
+	}
+}
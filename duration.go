@@ -0,0 +1,79 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+
+	iso8601Pattern = regexp.MustCompile(
+		`^(-)?P(?:(\d+(?:\.\d+)?)W)?(?:(\d+(?:\.\d+)?)D)?` +
+			`(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`,
+	)
+	iso8601Units = []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+)
+
+// parseISO8601Duration parses an ISO-8601 duration like `PT1H30M` or `P1DT12H`.
+// Years and months are rejected, since their length is not fixed, while days
+// and weeks are taken as 24 hours and 7 days respectively.
+func parseISO8601Duration(value string) (time.Duration, error) {
+	m := iso8601Pattern.FindStringSubmatch(value)
+	if m == nil || value == "P" || value == "-P" || value[len(value)-1] == 'T' {
+		return 0, fmt.Errorf("`%s` is not a valid ISO-8601 duration", value)
+	}
+	var d time.Duration
+	for i, unit := range iso8601Units {
+		if m[i+2] == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(m[i+2], 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(v * float64(unit))
+	}
+	if m[1] != "" {
+		d = -d
+	}
+	return d, nil
+}
+
+// DurationRange is a range of durations, decoded from its bounds separated
+// by `-` (e.g. `1s-5s`), as used for jitter and backoff delays.
+type DurationRange struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Decode implements the interface `flagstruct.Decoder`. The bounds are
+// split on the first `-` not leading a bound, since durations only contain
+// one as their sign (e.g. `-5s--1s`).
+func (r *DurationRange) Decode(value string) error {
+	i := -1
+	if value != "" {
+		i = strings.Index(value[1:], "-")
+	}
+	if i < 0 {
+		return fmt.Errorf("`%s` is not a valid duration range, expected `min-max`", value)
+	}
+	i++
+	lo, err := time.ParseDuration(value[:i])
+	if err != nil {
+		return err
+	}
+	hi, err := time.ParseDuration(value[i+1:])
+	if err != nil {
+		return err
+	}
+	if lo > hi {
+		return fmt.Errorf("the minimum `%v` of the duration range is greater than its maximum `%v`", lo, hi)
+	}
+	r.Min, r.Max = lo, hi
+	return nil
+}
@@ -0,0 +1,146 @@
+package flagstruct
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseISO8601Duration(t *testing.T) {
+	type test struct {
+		value    string
+		expected time.Duration
+		err      bool
+	}
+
+	tests := []*test{
+		{value: "PT1H30M", expected: 90 * time.Minute},
+		{value: "PT45S", expected: 45 * time.Second},
+		{value: "PT0.5S", expected: 500 * time.Millisecond},
+		{value: "P1DT12H", expected: 36 * time.Hour},
+		{value: "P2W", expected: 14 * 24 * time.Hour},
+		{value: "-PT10M", expected: -10 * time.Minute},
+		{value: "P", err: true},
+		{value: "PT", err: true},
+		{value: "P1DT", err: true},
+		{value: "P1Y", err: true},
+		{value: "P1M", err: true},
+		{value: "1h30m", err: true},
+		{value: "PT1M30H", err: true},
+	}
+
+	for i, ts := range tests {
+		d, err := parseISO8601Duration(ts.value)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+		if d != ts.expected {
+			t.Errorf("case #%d: wrong duration expected %v got %v", i, ts.expected, d)
+		}
+	}
+}
+
+func TestDecodeISO8601(t *testing.T) {
+	var s struct {
+		Timeout  time.Duration   `flag:"timeout,iso8601"`
+		Retries  []time.Duration `flag:"retries,iso8601"`
+		Interval time.Duration   `flag:"interval"`
+	}
+
+	p := Parser{Args: []string{"-timeout=PT1H30M", "-retries=PT1S;PT2S", "-interval=1m"}}
+	if err := p.Decode(&s); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if s.Timeout != 90*time.Minute || len(s.Retries) != 2 || s.Retries[1] != 2*time.Second || s.Interval != time.Minute {
+		t.Errorf("wrong assignment got %v, %v, %v", s.Timeout, s.Retries, s.Interval)
+	}
+
+	p.Args = []string{"-timeout=1h"}
+	if err := p.Decode(&s); err == nil {
+		t.Error("expected error for a Go duration on an iso8601 field")
+	}
+}
+
+func TestDecodeDefaultUnit(t *testing.T) {
+	type test struct {
+		args     []string
+		timeout  time.Duration
+		retries  []time.Duration
+		interval time.Duration
+		wantErr  bool
+	}
+
+	tests := []*test{
+		{args: []string{"-timeout=30", "-retries=1;2.5", "-interval=1"}, timeout: 30 * time.Second, retries: []time.Duration{time.Millisecond, 2500 * time.Microsecond}, interval: time.Hour},
+		{args: []string{"-timeout=1m", "-retries=1s;1ms", "-interval=30m"}, timeout: time.Minute, retries: []time.Duration{time.Second, time.Millisecond}, interval: 30 * time.Minute},
+		{args: []string{"-timeout=0"}},
+		{args: []string{"-timeout=abc"}, wantErr: true},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			Timeout  time.Duration   `flag:"timeout,defaultunit=s"`
+			Retries  []time.Duration `flag:"retries,defaultunit=ms"`
+			Interval time.Duration   `flag:"interval,defaultunit=h"`
+		}
+		err := (&Parser{Args: ts.args}).Decode(&s)
+		if ts.wantErr {
+			if err == nil {
+				t.Errorf("case #%d: expected an error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if s.Timeout != ts.timeout || !reflect.DeepEqual(s.Retries, ts.retries) || s.Interval != ts.interval {
+			t.Errorf("case #%d: wrong assignment got %v, %v, %v", i, s.Timeout, s.Retries, s.Interval)
+		}
+	}
+
+	var u struct {
+		Timeout time.Duration `flag:"timeout,defaultunit=days"`
+	}
+	if err := (&Parser{Args: []string{"-timeout=1"}}).Decode(&u); err == nil {
+		t.Error("expected an error with an invalid unit")
+	}
+}
+
+func TestDecodeDurationRange(t *testing.T) {
+	type test struct {
+		value    string
+		expected DurationRange
+		wantErr  bool
+	}
+
+	tests := []*test{
+		{value: "1s-5s", expected: DurationRange{Min: time.Second, Max: 5 * time.Second}},
+		{value: "100ms-1m30s", expected: DurationRange{Min: 100 * time.Millisecond, Max: 90 * time.Second}},
+		{value: "2s-2s", expected: DurationRange{Min: 2 * time.Second, Max: 2 * time.Second}},
+		{value: "-5s--1s", expected: DurationRange{Min: -5 * time.Second, Max: -time.Second}},
+		{value: "-1s-1s", expected: DurationRange{Min: -time.Second, Max: time.Second}},
+		{value: "5s-1s", wantErr: true},
+		{value: "5s", wantErr: true},
+		{value: "1s-", wantErr: true},
+		{value: "a-b", wantErr: true},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			Delay DurationRange `flag:"delay"`
+		}
+		err := (&Parser{Args: []string{"-delay=" + ts.value}}).Decode(&s)
+		if ts.wantErr {
+			if err == nil {
+				t.Errorf("case #%d: expected an error, got %+v", i, s.Delay)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if s.Delay != ts.expected {
+			t.Errorf("case #%d: wrong range expected %+v got %+v", i, ts.expected, s.Delay)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package flagstruct
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RegisterCodec registers both the function decoding the values of type t,
+// like RegisterType does, and the one encoding them back, used by Encode.
+// Registering both keeps the values of t round-tripping consistently.
+//
+//	p.RegisterCodec(reflect.TypeOf(Point{}), func(v reflect.Value) (string, error) {
+//		pt := v.Interface().(Point)
+//		return fmt.Sprintf("%d:%d", pt.X, pt.Y), nil
+//	}, func(dst reflect.Value, raw string) error {
+//		var pt Point
+//		_, err := fmt.Sscanf(raw, "%d:%d", &pt.X, &pt.Y)
+//		dst.Set(reflect.ValueOf(pt))
+//		return err
+//	})
+func (p *Parser) RegisterCodec(t reflect.Type, encode func(v reflect.Value) (string, error), decode func(dst reflect.Value, raw string) error) {
+	p.RegisterType(t, decode)
+	if p.encoders == nil {
+		p.encoders = make(map[reflect.Type]func(v reflect.Value) (string, error))
+	}
+	p.encoders[t] = encode
+}
+
+// Encode returns the command line arguments decoding into v, the inverse of
+// Decode: every tagged field holding a non-zero value (or any value, when it
+// has a default) is encoded as `-name=value`, following the separators,
+// layouts and bases of its annotation.
+// v must be a struct or a pointer to a struct.
+func Encode(v interface{}) ([]string, error) {
+	return (&Parser{}).Encode(v)
+}
+
+// Encode returns the command line arguments decoding into v, following the
+// same rules as the package level Encode function, and using the codecs
+// registered in the parser.
+func (p *Parser) Encode(v interface{}) ([]string, error) {
+	vl := reflect.Indirect(reflect.ValueOf(v))
+	if vl.Kind() != reflect.Struct {
+		return nil, ErrInvalidType
+	}
+	if !vl.CanAddr() {
+		c := reflect.New(vl.Type()).Elem()
+		c.Set(vl)
+		vl = c
+	}
+	return p.encode(vl, "", 0)
+}
+
+func (p *Parser) encode(vl reflect.Value, prefix string, depth int) ([]string, error) {
+	if depth >= p.maxDepth() {
+		return nil, nil
+	}
+	var args []string
+	t := vl.Type()
+	for i := 0; i < vl.NumField(); i++ {
+		ft := t.Field(i)
+		if ft.PkgPath != "" {
+			continue
+		}
+		f := vl.Field(i)
+		nested := false
+		if e := reflect.Indirect(f); e.Kind() == reflect.Struct && !isValueStruct(e.Type()) &&
+			!isCustom(e.Addr()) && !p.registered(f.Type()) && !p.registered(e.Type()) && tagOption(ft.Tag.Get("flag"), "encoding") == "" {
+			nestedArgs, err := p.encode(e, prefix+tagPrefix(ft.Tag.Get("flag")), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, nestedArgs...)
+			nested = true
+		}
+		tag := p.tag(ft, !nested)
+		if tag == "" || (nested && strings.HasPrefix(tag, ",")) {
+			continue
+		}
+		a, err := parseAnnotation(tag)
+		if err != nil {
+			return nil, err
+		}
+		// zero values are only encoded over a default, which would be
+		// decoded otherwise
+		_, osDefault := a.osDefaults[p.goos()]
+		if a.raw || (isZero(f) && !a.hasDefault && !osDefault) {
+			continue
+		}
+		a.name = prefix + a.name
+		if p.NameFunc != nil {
+			a.name = p.NameFunc(ft.Name, a.name)
+		}
+		value, err := p.encodeValue(f, a)
+		if err == nil && value == "" && !p.ClearOnEmpty {
+			err = errors.New("an empty value decodes into the default, enable ClearOnEmpty to encode it")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("flagstruct: could not encode flag '%s': %v", a.name, err)
+		}
+		args = append(args, "-"+a.name+"="+value)
+	}
+	return args, nil
+}
+
+// encodeValue returns the representation of f decoding back into it.
+func (p *Parser) encodeValue(f reflect.Value, a *annotation) (string, error) {
+	if encode, ok := p.encoders[f.Type()]; ok {
+		return encode(f)
+	}
+	if a.runes {
+		return string(f.Convert(reflect.TypeOf([]rune(nil))).Interface().([]rune)), nil
+	}
+	if a.iso8601 && f.Type() == durationType {
+		return encodeISO8601Duration(time.Duration(f.Int())), nil
+	}
+	switch f.Type() {
+	case timeType:
+		return f.Interface().(time.Time).Format(strings.Split(a.layout, "|")[0]), nil
+	case durationType:
+		return f.Interface().(time.Duration).String(), nil
+	case ipType:
+		return f.Interface().(net.IP).String(), nil
+	}
+	if m, ok := f.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	switch f.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if f.IsNil() {
+			return "", nil
+		}
+		return p.encodeValue(f.Elem(), a)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if a.stringer > 0 {
+			if s, ok := f.Interface().(fmt.Stringer); ok {
+				return s.String(), nil
+			}
+		}
+		if a.base != 0 {
+			return encodeBase(f.Int() < 0, uint64(abs(f.Int())), a), nil
+		}
+		return strconv.FormatInt(f.Int(), 10), nil
+	case reflect.Bool:
+		return strconv.FormatBool(f.Bool()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if a.base != 0 {
+			return encodeBase(false, f.Uint(), a), nil
+		}
+		return strconv.FormatUint(f.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(f.Float(), 'g', -1, f.Type().Bits()), nil
+	case reflect.String:
+		return f.String(), nil
+	case reflect.Slice, reflect.Array:
+		inner := *a
+		inner.sep = a.innerSep
+		elements := make([]string, f.Len())
+		for i := range elements {
+			e := f.Index(i)
+			var err error
+			if e.Kind() == reflect.Slice || e.Kind() == reflect.Map {
+				elements[i], err = p.encodeValue(e, &inner)
+			} else {
+				elements[i], err = p.encodeValue(e, a)
+			}
+			if err != nil {
+				return "", err
+			}
+			if elements[i], err = quote(elements[i], a.sep); err != nil {
+				return "", err
+			}
+		}
+		return strings.Join(elements, a.sep), nil
+	case reflect.Map:
+		set := f.Type().Elem().Kind() == reflect.Struct && f.Type().Elem().NumField() == 0
+		entries := make([]string, 0, f.Len())
+		for _, k := range f.MapKeys() {
+			key, err := p.encodeValue(k, a)
+			if err != nil {
+				return "", err
+			}
+			if set {
+				entries = append(entries, key)
+				continue
+			}
+			value, err := p.encodeValue(f.MapIndex(k), a)
+			if err != nil {
+				return "", err
+			}
+			entry := key + ":" + value
+			if strings.Contains(entry, a.sep) || strings.Contains(key, ":") {
+				return "", fmt.Errorf("the entry `%s` holds a separator, which can not be encoded", entry)
+			}
+			entries = append(entries, entry)
+		}
+		sort.Strings(entries)
+		return strings.Join(entries, a.sep), nil
+	}
+	if s, ok := f.Interface().(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+	return "", fmt.Errorf("type `%v` can not be encoded, register a codec for it", f.Type())
+}
+
+// encodeBase returns the integer of the given magnitude in the base of the
+// annotation, prefixed like decodeBase accepts it.
+func encodeBase(negative bool, v uint64, a *annotation) string {
+	digits := strconv.FormatUint(v, a.base)
+	if a.byteOrder == "le" {
+		digits = reverseBytes(digits)
+	}
+	digits = basePrefixes[a.base] + digits
+	if negative {
+		return "-" + digits
+	}
+	return digits
+}
+
+// abs returns the absolute value of v, as an unsigned integer, so the
+// minimum int64 is kept.
+func abs(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+	return uint64(v)
+}
+
+// encodeISO8601Duration returns d as an ISO-8601 duration in seconds (e.g.
+// `PT90S`), as accepted by parseISO8601Duration.
+func encodeISO8601Duration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	return sign + "PT" + strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+}
+
+// quote encloses the element in double quotes when it holds the separator,
+// so splitQuoted keeps it whole, which is not possible when it holds double
+// quotes as well.
+func quote(element, sep string) (string, error) {
+	if !strings.Contains(element, sep) {
+		return element, nil
+	}
+	if strings.Contains(element, `"`) {
+		return "", fmt.Errorf("the element `%s` holds both the separator and double quotes, which can not be encoded", element)
+	}
+	return `"` + element + `"`, nil
+}
@@ -0,0 +1,167 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestEncode(t *testing.T) {
+	type server struct {
+		Host string `flag:"host"`
+		Port int    `flag:"port"`
+	}
+	type config struct {
+		Server   server            `flag:",prefix=server."`
+		Origin   point             `flag:"origin"`
+		Path     []point           `flag:"path"`
+		Tags     []string          `flag:"tags,sep=|"`
+		Limits   map[string]int    `flag:"limits"`
+		Matrix   [][]int           `flag:"matrix"`
+		Timeout  time.Duration     `flag:"timeout"`
+		Started  time.Time         `flag:"started,layout=2006-01-02"`
+		Ratio    float64           `flag:"ratio"`
+		Verbose  bool              `flag:"verbose"`
+		Color    hue               `flag:"color,stringer"`
+		Features map[string]bool   `flag:"features"`
+		Labels   map[string]string `flag:"labels"`
+		Empty    string            `flag:"empty"`
+	}
+
+	p := Parser{}
+	p.RegisterCodec(reflect.TypeOf(point{}), func(v reflect.Value) (string, error) {
+		pt := v.Interface().(point)
+		return fmt.Sprintf("%d:%d", pt.X, pt.Y), nil
+	}, func(dst reflect.Value, raw string) error {
+		var pt point
+		if _, err := fmt.Sscanf(raw, "%d:%d", &pt.X, &pt.Y); err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(pt))
+		return nil
+	})
+
+	c := config{
+		Server:   server{Host: "localhost", Port: 8080},
+		Origin:   point{X: 1, Y: -2},
+		Path:     []point{{0, 0}, {3, 4}},
+		Tags:     []string{"a", "b"},
+		Limits:   map[string]int{"b": 2, "a": 1},
+		Matrix:   [][]int{{1, 2}, {3}},
+		Timeout:  90 * time.Second,
+		Started:  time.Date(2020, 5, 17, 0, 0, 0, 0, time.UTC),
+		Ratio:    0.25,
+		Verbose:  true,
+		Color:    blue,
+		Features: map[string]bool{"x": true},
+	}
+	args, err := p.Encode(&c)
+	if err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	expected := []string{
+		"-server.host=localhost",
+		"-server.port=8080",
+		"-origin=1:-2",
+		"-path=0:0;3:4",
+		"-tags=a|b",
+		"-limits=a:1;b:2",
+		"-matrix=1,2;3",
+		"-timeout=1m30s",
+		"-started=2020-05-17",
+		"-ratio=0.25",
+		"-verbose=true",
+		"-color=Blue",
+		"-features=x:true",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("wrong arguments expected %q got %q", expected, args)
+	}
+
+	var decoded config
+	p.Args = args
+	if err := p.Decode(&decoded); err != nil {
+		t.Fatalf("unexpected error decoding the encoded arguments: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, c) {
+		t.Errorf("wrong round trip expected %+v got %+v", c, decoded)
+	}
+
+	if _, err := Encode(struct {
+		Origin point `flag:"origin"`
+	}{Origin: point{X: 1}}); err == nil {
+		t.Error("expected an error encoding a type without codec")
+	}
+	if _, err := Encode("config"); err != ErrInvalidType {
+		t.Errorf("expected %v, got %v", ErrInvalidType, err)
+	}
+}
+
+func TestEncodeOptions(t *testing.T) {
+	type config struct {
+		Mask    uint8         `flag:"mask,base=16"`
+		Offset  int           `flag:"offset,base=16"`
+		Magic   uint32        `flag:"magic,base=16,byteorder=le"`
+		Mode    int           `flag:"mode,base=8"`
+		Timeout time.Duration `flag:"timeout,iso8601"`
+		On      bool          `flag:"on,default=true"`
+		Retries int           `flag:"retries,default=3"`
+		Tags    []string      `flag:"tags"`
+	}
+
+	c := config{
+		Mask:    255,
+		Offset:  -16,
+		Magic:   0x12345678,
+		Mode:    0755,
+		Timeout: 90 * time.Second,
+		Tags:    []string{"a;b", "c"},
+	}
+	args, err := Encode(&c)
+	if err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	expected := []string{
+		"-mask=0xff",
+		"-offset=-0x10",
+		"-magic=0x78563412",
+		"-mode=0o755",
+		"-timeout=PT90S",
+		"-on=false",
+		"-retries=0",
+		`-tags="a;b";c`,
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("wrong arguments expected %q got %q", expected, args)
+	}
+
+	var decoded config
+	if err := (&Parser{Args: args}).Decode(&decoded); err != nil {
+		t.Fatalf("unexpected error decoding the encoded arguments: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, c) {
+		t.Errorf("wrong round trip expected %+v got %+v", c, decoded)
+	}
+
+	if _, err := Encode(struct {
+		Tags []string `flag:"tags"`
+	}{Tags: []string{`"a";b`}}); err == nil {
+		t.Error("expected an error encoding an element holding the separator and quotes")
+	}
+	if _, err := Encode(struct {
+		Name string `flag:"name,default=x"`
+	}{}); err == nil {
+		t.Error("expected an error encoding an empty value over a default")
+	}
+	args, err = (&Parser{ClearOnEmpty: true}).Encode(struct {
+		Name string `flag:"name,default=x"`
+	}{})
+	if err != nil || !reflect.DeepEqual(args, []string{"-name="}) {
+		t.Errorf("wrong arguments with ClearOnEmpty got %q (%v)", args, err)
+	}
+}
@@ -0,0 +1,109 @@
+package flagstruct
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// decodeEncoded decodes flagVal into f according to the `encoding` option of
+// the annotation.
+func decodeEncoded(f *reflect.Value, flagVal string) error {
+	v := reflect.New(f.Type())
+	if err := json.Unmarshal([]byte(flagVal), v.Interface()); err != nil {
+		return err
+	}
+	f.Set(v.Elem())
+	return nil
+}
+
+// decodeOmitted applies the tag defaults (and required flags) of the fields
+// of the struct held by f to the ones the encoded value flagVal omits, while
+// the fields it holds are kept, even when they are zero values.
+func (p *Parser) decodeOmitted(f *reflect.Value, flagVal string) error {
+	s := reflect.Indirect(*f)
+	if s.Kind() != reflect.Struct || !s.CanAddr() {
+		return nil
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(flagVal), &raw); err != nil {
+		return nil
+	}
+	missing, err := p.omitted(s, raw, "")
+	if err != nil {
+		return err
+	}
+	if len(missing) != 0 {
+		return &MissingError{Names: missing}
+	}
+	return nil
+}
+
+// omitted decodes the tag defaults of the fields of s absent from raw,
+// returning the names of the required ones.
+func (p *Parser) omitted(s reflect.Value, raw map[string]json.RawMessage, prefix string) ([]string, error) {
+	var missing []string
+	for i := 0; i < s.NumField(); i++ {
+		ft := s.Type().Field(i)
+		if ft.PkgPath != "" {
+			continue
+		}
+		f := s.Field(i)
+		key := strings.Split(ft.Tag.Get("json"), ",")[0]
+		value, present := jsonField(raw, key, ft.Name)
+		if nested := reflect.Indirect(f); nested.Kind() == reflect.Struct && !isValueStruct(nested.Type()) && !isCustom(nested.Addr()) {
+			fields := raw
+			if !ft.Anonymous || key != "" {
+				fields = nil
+				if present && json.Unmarshal(value, &fields) != nil {
+					continue
+				}
+			}
+			names, err := p.omitted(nested, fields, prefix+tagPrefix(ft.Tag.Get("flag")))
+			if err != nil {
+				return nil, err
+			}
+			missing = append(missing, names...)
+			continue
+		}
+		tag := p.tag(ft, true)
+		if present || tag == "" || strings.HasPrefix(tag, ",") || !f.CanSet() {
+			continue
+		}
+		a, err := parseAnnotation(tag)
+		if err != nil {
+			return nil, err
+		}
+		a.name = prefix + a.name
+		if a.required {
+			missing = append(missing, a.name)
+			continue
+		}
+		if a.hasDefault && a.defaultValue != "" {
+			if err := p.decodeField(&f, expandDefault(a.defaultValue), a); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return missing, nil
+}
+
+// jsonField returns the encoded value of the field with the given json key
+// (or Go name) from raw, matched case-insensitively like encoding/json does.
+func jsonField(raw map[string]json.RawMessage, key, name string) (json.RawMessage, bool) {
+	if key == "-" {
+		return nil, false
+	}
+	if key == "" {
+		key = name
+	}
+	if v, ok := raw[key]; ok {
+		return v, true
+	}
+	for k, v := range raw {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
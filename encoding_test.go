@@ -0,0 +1,77 @@
+package flagstruct
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSONEncoding(t *testing.T) {
+	type retry struct {
+		Attempts int    `flag:"attempts,default=3" json:"attempts"`
+		Backoff  string `flag:"backoff,default=1s" json:"backoff"`
+		Policy   string `flag:"policy,required" json:"policy"`
+	}
+	type test struct {
+		Retry  retry          `flag:"retry,encoding=json"`
+		Labels map[string]int `flag:"labels,encoding=json"`
+		Hosts  *[]string      `flag:"hosts,encoding=json"`
+	}
+
+	var ts test
+	p := Parser{Args: []string{
+		`-retry={"attempts": 5, "policy": "exponential"}`,
+		`-labels={"a": 1, "b": 2}`,
+		`-hosts=["a", "b"]`,
+	}}
+	if err := p.Decode(&ts); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if expected := (retry{Attempts: 5, Backoff: "1s", Policy: "exponential"}); ts.Retry != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts.Retry)
+	}
+	if !reflect.DeepEqual(ts.Labels, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("wrong labels got %v", ts.Labels)
+	}
+	if ts.Hosts == nil || !reflect.DeepEqual(*ts.Hosts, []string{"a", "b"}) {
+		t.Errorf("wrong hosts got %v", ts.Hosts)
+	}
+
+	type decode struct {
+		arg string
+		err string
+	}
+	tests := []*decode{
+		{
+			arg: `-retry={"attempts": 5}`,
+			err: "flagstruct: flag 'policy' is missing",
+		},
+		{
+			arg: `-retry={"attempts": "five", "policy": "linear"}`,
+			err: "flagstruct: could not decode value `{\"attempts\": \"five\", \"policy\": \"linear\"}` to kind `struct`: json: cannot unmarshal",
+		},
+	}
+	for i, ts := range tests {
+		var result test
+		p.Args = []string{ts.arg}
+		if err := p.Decode(&result); err == nil || !strings.HasPrefix(err.Error(), ts.err) {
+			t.Errorf("case #%d: wrong error expected %s got %v", i, ts.err, err)
+		}
+	}
+
+	p.Args = []string{`-retry={"attempts": 0, "backoff": "", "policy": "linear"}`}
+	var zeros test
+	if err := p.Decode(&zeros); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if expected := (retry{Policy: "linear"}); zeros.Retry != expected {
+		t.Errorf("expected explicit zero values to be kept %+v got %+v", expected, zeros.Retry)
+	}
+
+	var invalid struct {
+		Retry retry `flag:"retry,encoding=yaml"`
+	}
+	if err := p.Decode(&invalid); err == nil || err.Error() != "flagstruct: malformed annotation, unknown encoding `yaml`" {
+		t.Errorf("wrong error for an unknown encoding got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package flagstruct
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// UnusedEnvError is returned when Parser.UnusedEnvPrefix is set and some of
+// the environment variables starting with it do not correspond to any field.
+type UnusedEnvError struct {
+	// Names of the unused environment variables, sorted alphabetically.
+	Names []string
+}
+
+func (e *UnusedEnvError) Error() string {
+	return fmt.Sprintf("flagstruct: environment variables %v do not correspond to any flag", e.Names)
+}
+
+// DecodeEnv decodes environment variables into the provided target,
+// following the same rules as Decode but never looking at the command line
+// arguments. Each field is resolved from the variable named through the
+// ",env=NAME" tag option, or from its flag name in upper snake case when
+// omitted (e.g. `db-host` is resolved from `DB_HOST`).
+func DecodeEnv(v interface{}) error {
+	p := Parser{Args: []string{}, Precedence: []Origin{OriginEnv}}
+	return p.Decode(v)
+}
+
+// DecodeAuto decodes the command line arguments and the environment variables
+// into the provided target, resolving every field from its flag first and
+// falling back to its environment variable (see DecodeEnv for the naming).
+// Every validation applies no matter the origin of the value.
+func DecodeAuto(v interface{}) error {
+	var p Parser
+	return p.DecodeAuto(v)
+}
+
+// DecodeAuto decodes like the package level DecodeAuto does, using the
+// precedence list of the parser when provided.
+func (p *Parser) DecodeAuto(v interface{}) error {
+	auto := *p
+	if len(auto.Precedence) == 0 {
+		auto.Precedence = []Origin{OriginArgs, OriginEnv}
+	}
+	return auto.Decode(v)
+}
+
+// envNames returns the environment variable names of the annotation, in the
+// order they are consulted, inferred from the flag name when the `env`
+// option is not provided. Several names may be provided separated by
+// semicolon (e.g. `env=APP_TOKEN;TOKEN`).
+func (a *annotation) envNames() []string {
+	if a.env != "" {
+		return strings.Split(a.env, ";")
+	}
+	return []string{strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(a.name))}
+}
+
+// checkUnusedEnv looks for environment variables starting with the
+// configured prefix which were not consumed by any field.
+func (p *Parser) checkUnusedEnv(s *state) error {
+	if p.UnusedEnvPrefix == "" {
+		return nil
+	}
+	var names []string
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if strings.HasPrefix(name, p.UnusedEnvPrefix) && !s.envNames[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return &UnusedEnvError{Names: names}
+}
@@ -0,0 +1,267 @@
+package flagstruct
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func setenv(t *testing.T, key, value string) {
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatalf("could not set env var %s: %v", key, err)
+	}
+}
+
+func unsetenv(keys ...string) {
+	for _, key := range keys {
+		_ = os.Unsetenv(key)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	type test struct {
+		tag      string
+		expected []string
+	}
+
+	tests := []*test{
+		{tag: "host", expected: []string{"HOST"}},
+		{tag: "db-host", expected: []string{"DB_HOST"}},
+		{tag: "db.max-conns", expected: []string{"DB_MAX_CONNS"}},
+		{tag: "db-host,env=DATABASE_HOST", expected: []string{"DATABASE_HOST"}},
+		{tag: "token,env=APP_TOKEN;TOKEN", expected: []string{"APP_TOKEN", "TOKEN"}},
+	}
+
+	for i, ts := range tests {
+		a, err := parseAnnotation(ts.tag)
+		if err != nil {
+			t.Fatalf("case #%d: unexpected error %v", i, err)
+		}
+		if result := a.envNames(); !reflect.DeepEqual(result, ts.expected) {
+			t.Errorf("case #%d: wrong result expected %v got %v", i, ts.expected, result)
+		}
+	}
+}
+
+func TestDecodeEnv(t *testing.T) {
+	type test struct {
+		Host    string        `flag:"db-host,default=127.0.0.1"`
+		User    string        `flag:"db-user,required,env=APP_DB_USER"`
+		Level   string        `flag:"level,allowed=debug;info,default=info"`
+		Timeout time.Duration `flag:"timeout"`
+	}
+	os.Args = []string{"./example", "-db-host=example.com"}
+	defer unsetenv("DB_HOST", "APP_DB_USER", "LEVEL", "TIMEOUT")
+
+	var ts test
+	if err := DecodeEnv(&ts); err == nil {
+		t.Error("expected an error for required field db-user")
+	}
+
+	setenv(t, "APP_DB_USER", "root")
+	setenv(t, "TIMEOUT", "2s")
+	if err := DecodeEnv(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{Host: "127.0.0.1", User: "root", Level: "info", Timeout: 2 * time.Second}
+	if ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	setenv(t, "LEVEL", "trace")
+	if err := DecodeEnv(&ts); err == nil {
+		t.Error("expected error for not allowed value in env")
+	}
+}
+
+func TestParserPrecedence(t *testing.T) {
+	type test struct {
+		Host string `flag:"host"`
+		Port string `flag:"port"`
+	}
+	defer unsetenv("HOST", "PORT")
+	setenv(t, "HOST", "env-host")
+	setenv(t, "PORT", "80")
+	args := []string{"-host=args-host"}
+
+	var ts test
+	p := Parser{Args: args, Precedence: []Origin{OriginArgs, OriginEnv}}
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if expected := (test{Host: "args-host", Port: "80"}); ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	ts = test{}
+	p = Parser{Args: args, Precedence: []Origin{OriginEnv, OriginArgs}}
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if expected := (test{Host: "env-host", Port: "80"}); ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+}
+
+func TestDecodeEnvNames(t *testing.T) {
+	type test struct {
+		Token string `flag:"token,required,env=APP_TOKEN;TOKEN"`
+	}
+	defer unsetenv("APP_TOKEN", "TOKEN")
+
+	var ts test
+	setenv(t, "TOKEN", "legacy")
+	if err := DecodeEnv(&ts); err != nil || ts.Token != "legacy" {
+		t.Errorf("expected the legacy token, got %s (%v)", ts.Token, err)
+	}
+	setenv(t, "APP_TOKEN", "current")
+	if err := DecodeEnv(&ts); err != nil || ts.Token != "current" {
+		t.Errorf("expected the current token, got %s (%v)", ts.Token, err)
+	}
+	unsetenv("APP_TOKEN", "TOKEN")
+	if err := DecodeEnv(&ts); err == nil {
+		t.Error("expected error for required field token")
+	}
+	p := Parser{Args: []string{}, Precedence: []Origin{OriginEnv}, UnusedEnvPrefix: "TOK"}
+	setenv(t, "TOKEN", "legacy")
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error for a consumed legacy name: %v", err)
+	}
+}
+
+func TestDecodeAuto(t *testing.T) {
+	type test struct {
+		Host  string `flag:"db-host,default=localhost"`
+		User  string `flag:"db-user,required,env=APP_DB_USER"`
+		Port  int    `flag:"db-port"`
+		Level string `flag:"level,allowed=debug;info"`
+	}
+	os.Args = []string{"./example", "-db-host=example.com", "-db-port=5432"}
+	defer unsetenv("DB_HOST", "APP_DB_USER", "DB_PORT", "LEVEL")
+	setenv(t, "DB_HOST", "env.example.com")
+	setenv(t, "APP_DB_USER", "root")
+	setenv(t, "DB_PORT", "3306")
+
+	var ts test
+	if err := DecodeAuto(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if expected := (test{Host: "example.com", User: "root", Port: 5432}); ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	ts = test{}
+	p := Parser{Args: []string{"-db-host=example.com"}, Precedence: []Origin{OriginEnv, OriginArgs}}
+	if err := p.DecodeAuto(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if expected := (test{Host: "env.example.com", User: "root", Port: 3306}); ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	setenv(t, "LEVEL", "trace")
+	if err := DecodeAuto(&ts); err == nil {
+		t.Error("expected error for a not allowed environment value")
+	}
+	unsetenv("LEVEL", "APP_DB_USER")
+	if err := DecodeAuto(&ts); err == nil {
+		t.Error("expected error for required field db-user")
+	}
+}
+
+func TestRequiredEnv(t *testing.T) {
+	type test struct {
+		Key   string `flag:"key,required-env=APP_ENV=production"`
+		Level string `flag:"level,default=debug,required-env=APP_ENV=production"`
+	}
+	defer unsetenv("APP_ENV")
+
+	var ts test
+	p := Parser{Args: []string{}}
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error without the condition: %v", err)
+	}
+	setenv(t, "APP_ENV", "development")
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a non matching condition: %v", err)
+	}
+
+	setenv(t, "APP_ENV", "production")
+	if err := p.Decode(&ts); err == nil || err.Error() != "flagstruct: flags 'key', 'level' are missing" {
+		t.Errorf("wrong error expected flags 'key' and 'level' to be missing got %v", err)
+	}
+	p.Args = []string{"-key=secret"}
+	if err := p.Decode(&ts); err == nil || err.Error() != "flagstruct: flag 'level' is missing" {
+		t.Errorf("wrong error expected flag 'level' to be missing got %v", err)
+	}
+	p.Args = []string{"-key=secret", "-level=info"}
+	if err := p.Decode(&ts); err != nil || ts.Key != "secret" || ts.Level != "info" {
+		t.Errorf("unexpected result with the required flags %+v (%v)", ts, err)
+	}
+
+	var malformed struct {
+		Key string `flag:"key,required-env=APP_ENV"`
+	}
+	if err := p.Decode(&malformed); err == nil {
+		t.Error("expected error for a malformed required-env option")
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	type test struct {
+		Config string `flag:"config,default=$APP_HOME/.config/app"`
+		Cache  string `flag:"cache,default=${APP_HOME}/cache"`
+		Data   string `flag:"data"`
+	}
+	defer unsetenv("APP_HOME")
+	setenv(t, "APP_HOME", "/home/app")
+	args := []string{"-data=$APP_HOME/data"}
+
+	var ts test
+	if err := (&Parser{Args: args}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{Config: "/home/app/.config/app", Cache: "/home/app/cache", Data: "$APP_HOME/data"}
+	if ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	ts = test{}
+	if err := (&Parser{Args: args, ExpandEnv: true}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if ts.Data != "/home/app/data" {
+		t.Errorf("wrong expanded value expected /home/app/data got %s", ts.Data)
+	}
+}
+
+func TestUnusedEnv(t *testing.T) {
+	type test struct {
+		Host string `flag:"app-host"`
+		Port int    `flag:"port,env=APP_PORT"`
+	}
+	defer unsetenv("APP_HOST", "APP_PORT", "APP_PROT", "APP_HOTS")
+	setenv(t, "APP_HOST", "localhost")
+	setenv(t, "APP_PORT", "80")
+
+	var ts test
+	p := Parser{Args: []string{}, Precedence: []Origin{OriginEnv}, UnusedEnvPrefix: "APP_"}
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error without stray env vars: %v", err)
+	}
+
+	setenv(t, "APP_PROT", "80")
+	setenv(t, "APP_HOTS", "localhost")
+	err := p.Decode(&ts)
+	unused, ok := err.(*UnusedEnvError)
+	if !ok {
+		t.Fatalf("expected an unused env error got %v", err)
+	}
+	if expected := []string{"APP_HOTS", "APP_PROT"}; !reflect.DeepEqual(unused.Names, expected) {
+		t.Errorf("wrong unused env vars expected %v got %v", expected, unused.Names)
+	}
+	if ts.Host != "localhost" || ts.Port != 80 {
+		t.Errorf("expected fields to be decoded anyway, got %+v", ts)
+	}
+}
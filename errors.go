@@ -0,0 +1,32 @@
+package flagstruct
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Errors is a list of errors occurred while decoding, it is returned when
+// the decoding process does not stop at the first error.
+type Errors []error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// MissingError is returned when required flags are missing, once every other
+// field was decoded, listing all of them.
+type MissingError struct {
+	// Names of the missing flags, in the order of the fields.
+	Names []string
+}
+
+func (e *MissingError) Error() string {
+	if len(e.Names) == 1 {
+		return fmt.Sprintf("flagstruct: flag '%s' is missing", e.Names[0])
+	}
+	return fmt.Sprintf("flagstruct: flags '%s' are missing", strings.Join(e.Names, "', '"))
+}
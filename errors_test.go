@@ -0,0 +1,39 @@
+package flagstruct
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	err := Errors{errors.New("flagstruct: a"), errors.New("flagstruct: b")}
+	if expected := "flagstruct: a; flagstruct: b"; err.Error() != expected {
+		t.Errorf("wrong message expected %s got %s", expected, err.Error())
+	}
+}
+
+func TestMissingError(t *testing.T) {
+	type test struct {
+		Host string `flag:"host,required"`
+		Port int    `flag:"port,default=80"`
+		User string `flag:"user,required"`
+		Pass string `flag:"pass,required"`
+	}
+
+	var ts test
+	err := (&Parser{Args: []string{"-user=root", "-port=8080"}}).Decode(&ts)
+	if expected := "flagstruct: flags 'host', 'pass' are missing"; err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected %s got %v", expected, err)
+	}
+	if m, ok := err.(*MissingError); !ok || len(m.Names) != 2 {
+		t.Errorf("expected a *MissingError holding both names, got %#v", err)
+	}
+	if ts.User != "root" || ts.Port != 8080 {
+		t.Errorf("expected the other fields to be decoded, got %+v", ts)
+	}
+
+	err = (&Parser{Args: []string{"-user=root", "-port=a"}}).Decode(&ts)
+	if _, ok := err.(*MissingError); err == nil || ok {
+		t.Errorf("expected other errors to fail fast, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package flagstruct
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	fileType = reflect.TypeOf(os.File{})
+
+	fileFlags = map[string]int{
+		"rdonly": os.O_RDONLY,
+		"wronly": os.O_WRONLY,
+		"rdwr":   os.O_RDWR,
+		"append": os.O_APPEND,
+		"create": os.O_CREATE,
+		"excl":   os.O_EXCL,
+		"sync":   os.O_SYNC,
+		"trunc":  os.O_TRUNC,
+	}
+)
+
+const (
+	defaultFileFlags = os.O_APPEND | os.O_CREATE | os.O_WRONLY
+	defaultFilePerm  = 0644
+)
+
+// parseFileFlags parses the `|` separated names of the os.OpenFile flags
+// (e.g. `rdwr|create|trunc`).
+func parseFileFlags(names string) (int, error) {
+	var flags int
+	for _, name := range strings.Split(names, "|") {
+		flag, ok := fileFlags[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return 0, fmt.Errorf("flagstruct: malformed annotation, invalid file flag `%s`", name)
+		}
+		flags |= flag
+	}
+	return flags, nil
+}
+
+// parseFilePerm parses the octal permissions used to create files (e.g.
+// `0600`).
+func parseFilePerm(perm string) (os.FileMode, error) {
+	v, err := strconv.ParseUint(perm, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("flagstruct: malformed annotation, invalid file permissions `%s`", perm)
+	}
+	return os.FileMode(v), nil
+}
+
+// decodeFile opens the path provided by flagVal into an *os.File field,
+// using the `fileflags` and `perm` options of the annotation. The caller owns
+// the opened file, and is responsible for closing it. While validating, the
+// file is only checked, so it is neither created nor truncated, and the field
+// is left untouched.
+func decodeFile(f *reflect.Value, flagVal string, a *annotation) error {
+	if a.validating {
+		return checkFile(flagVal, a.fileFlags)
+	}
+	file, err := os.OpenFile(flagVal, a.fileFlags, a.perm)
+	if err != nil {
+		return err
+	}
+	f.Set(reflect.ValueOf(file))
+	return nil
+}
+
+// checkFile reports whether the path could be opened with the given flags,
+// opening (and closing) it without the flags creating or truncating it.
+func checkFile(path string, flags int) error {
+	file, err := os.OpenFile(path, flags&^(os.O_CREATE|os.O_EXCL|os.O_TRUNC), 0)
+	if err == nil {
+		file.Close()
+		if flags&os.O_CREATE != 0 && flags&os.O_EXCL != 0 {
+			return &os.PathError{Op: "open", Path: path, Err: os.ErrExist}
+		}
+		return nil
+	}
+	if os.IsNotExist(err) && flags&os.O_CREATE != 0 {
+		_, err = os.Stat(filepath.Dir(path))
+	}
+	return err
+}
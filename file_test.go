@@ -0,0 +1,109 @@
+package flagstruct
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagstruct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	if err := ioutil.WriteFile(path, []byte("first\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var s struct {
+		Log    *os.File `flag:"log"`
+		Output *os.File `flag:"output,fileflags=wronly|create|trunc,perm=0600"`
+	}
+	output := filepath.Join(dir, "output")
+	p := Parser{Args: []string{"-log=" + path, "-output=" + output}}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if _, err := s.Log.WriteString("second\n"); err != nil {
+		t.Errorf("unexpected error writing the file: %v", err)
+	}
+	s.Log.Close()
+	s.Output.Close()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "first\nsecond\n" {
+		t.Errorf("wrong content expected the file to be appended, got %q", content)
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatalf("expected the file to be created: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm&^0600 != 0 {
+		t.Errorf("wrong permissions expected at most 0600 got %v", perm)
+	}
+
+	p.Args = []string{"-log=" + filepath.Join(dir, "missing", "app.log")}
+	if err := p.Decode(&s); err == nil {
+		t.Error("expected error for a path that can not be opened")
+	}
+
+	var invalid struct {
+		Log *os.File `flag:"log,fileflags=append|bogus"`
+	}
+	if err := p.Decode(&invalid); err == nil || err.Error() != "flagstruct: malformed annotation, invalid file flag `bogus`" {
+		t.Errorf("wrong error for an invalid file flag got %v", err)
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagstruct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	if err := ioutil.WriteFile(path, []byte("keep\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "output")
+
+	var s struct {
+		Log    *os.File `flag:"log,fileflags=wronly|trunc"`
+		Output *os.File `flag:"output"`
+	}
+	p := Parser{Args: []string{"-log=" + path, "-output=" + output}}
+	if err := p.Validate(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("expected the file not to be created while validating, got %v", err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "keep\n" {
+		t.Errorf("expected the file not to be truncated while validating, got %q", content)
+	}
+	if s.Log != nil || s.Output != nil {
+		t.Error("expected the target to be left untouched")
+	}
+
+	p.Args = []string{"-output=" + filepath.Join(dir, "missing", "output")}
+	if err := p.Validate(&s); err == nil {
+		t.Error("expected error for a path that can not be created")
+	}
+
+	ch := make(chan []string, 1)
+	ch <- []string{"-output=" + output}
+	close(ch)
+	p.DecodeStream(&s, ch, func(err error) { t.Errorf("unexpected error %v", err) })
+	if s.Output == nil {
+		t.Fatal("expected the file to be opened")
+	}
+	s.Output.Close()
+}
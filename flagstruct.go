@@ -1,10 +1,13 @@
 package flagstruct
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -17,32 +20,253 @@ var (
 	ErrInvalidType = errors.New("flagstruct: non-pointer passed to decode")
 )
 
+// DefaultMaxDepth is the number of nested structs decoded when
+// Parser.MaxDepth is not provided.
+const DefaultMaxDepth = 64
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	decoderType = reflect.TypeOf((*Decoder)(nil)).Elem()
+)
+
 // Decoder is the interface implemented by an object that can decode an
 // environment variable string representation of itself.
 type Decoder interface {
 	Decode(string) error
 }
 
-func lookup(args []string, t string) string {
+// Setter is the interface implemented by an object that can set itself
+// from a typed value, inferred from the command line argument as a bool,
+// int64, float64 or string, in that order.
+type Setter interface {
+	SetFromFlag(v interface{}) error
+}
+
+// AfterDecoder is the interface implemented by structs that need to do some
+// post-processing once their fields were decoded, like computing the value
+// of fields derived from others. Nested structs are processed before the
+// struct holding them.
+type AfterDecoder interface {
+	AfterDecode() error
+}
+
+// lookup returns the value of the flag matching the given long name or
+// short alias. Short aliases only match single dash arguments (`-v=true`),
+// while long names match both single and double dash ones (`--verbose=true`).
+func lookup(args []string, name, short string) string {
+	if values := lookupAll(args, name, short); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// lookupAll returns the values of every occurrence of the flag matching the
+// given long name or short alias, in the same order as in args.
+func lookupAll(args []string, name, short string) []string {
+	return lookupFlags(args, name, short, false)
+}
+
+// lookupFlags returns the values of every occurrence of the flag like
+// lookupAll does, while bare occurrences (`-name`) are taken as `true` when
+// bare is set, as expected from boolean flags.
+func lookupFlags(args []string, name, short string, bare bool) []string {
+	var values []string
+	name = strings.TrimLeft(name, "-")
 	for _, arg := range args {
-		p := strings.Split(arg, "=")
-		if len(p) < 2 {
+		dashes, n, value, ok := splitArg(arg)
+		if !ok && bare && !strings.Contains(arg, "=") {
+			dashes, n, value, ok = splitArg(arg + "=true")
+		}
+		if !ok {
 			continue
 		}
-		if strings.HasSuffix(p[0], t) {
-			return p[1]
+		if n == name || (dashes == 1 && short != "" && n == short) {
+			values = append(values, value)
 		}
 	}
-	return ""
+	return values
+}
+
+// splitArg splits an argument of the form `-name=value` or `--name=value`
+// into its parts, reporting whether the argument has that form at all.
+func splitArg(arg string) (dashes int, name, value string, ok bool) {
+	switch {
+	case strings.HasPrefix(arg, "--"):
+		dashes = 2
+	case strings.HasPrefix(arg, "-"):
+		dashes = 1
+	default:
+		return 0, "", "", false
+	}
+	p := strings.SplitN(arg[dashes:], "=", 2)
+	if len(p) < 2 || p[0] == "" {
+		return 0, "", "", false
+	}
+	return dashes, p[0], p[1], true
+}
+
+// filterPrefix returns the arguments whose name starts with prefix, with the
+// prefix removed from their names. Bare arguments (`-prefix.verbose`) are
+// kept as well, as expected from boolean flags.
+func filterPrefix(args []string, prefix string) []string {
+	filtered := make([]string, 0, len(args))
+	for _, arg := range args {
+		dashes, name, value, ok := splitArg(arg)
+		if !ok && !strings.Contains(arg, "=") {
+			if dashes, name, _, ok = splitArg(arg + "="); ok && strings.HasPrefix(name, prefix) {
+				filtered = append(filtered, arg[:dashes]+name[len(prefix):])
+			}
+			continue
+		}
+		if !ok || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		filtered = append(filtered, arg[:dashes]+name[len(prefix):]+"="+value)
+	}
+	return filtered
 }
 
 func inSlice(values []string, target string) bool {
-	for _, value := range values {
+	return indexOf(values, target) >= 0
+}
+
+// indexOf returns the index of target in values, or -1 when missing.
+func indexOf(values []string, target string) int {
+	for i, value := range values {
 		if value == target {
-			return true
+			return i
 		}
 	}
-	return false
+	return -1
+}
+
+// Origin identifies a place where flag values are resolved from.
+type Origin int
+
+const (
+	// OriginArgs resolves flag values from the command line arguments.
+	OriginArgs Origin = iota
+	// OriginEnv resolves flag values from the environment variables named
+	// through the `env` tag option, or inferred from the flag name in upper
+	// snake case (e.g. `db-host` is resolved from `DB_HOST`).
+	OriginEnv
+)
+
+// Parser decodes command line arguments into structs. Its zero value
+// behaves as Decode does, while its fields customize the decoding process.
+type Parser struct {
+	// Args are the command line arguments to decode, os.Args[1:] when nil.
+	Args []string
+	// JSONFallback uses the name declared in the `json` struct tag as flag
+	// name for fields without a `flag` struct tag. Fields tagged with
+	// `json:"-"` are skipped.
+	JSONFallback bool
+	// PrefixFilter restricts the decoding to the arguments whose name starts
+	// with the given prefix, which is removed before matching the flag names.
+	PrefixFilter string
+	// Precedence lists the origins flag values are resolved from, the first
+	// one holding a value wins. Only the command line arguments are
+	// considered when empty.
+	Precedence []Origin
+	// Sources lists the places flag values are resolved from, where every
+	// source overrides the values of the sources before it (e.g. a config
+	// file, followed by EnvSource and ArgsSource). When provided, Precedence
+	// is ignored.
+	Sources []Source
+	// InferNames infers the flag name of the fields without a `flag` struct
+	// tag from their name, using the configured NameStyle.
+	InferNames bool
+	// NameStyle is the style of the inferred flag names, KebabCase by default.
+	NameStyle NameStyle
+	// NameFunc transforms the flag name of every field, after prefixes are
+	// applied and before looking it up (e.g. namespacing it by tenant). It
+	// receives the field name and the flag name, which is kept when nil.
+	NameFunc func(fieldName, tagName string) string
+	// Strict returns an error for the slice elements and map entries that
+	// could not be decoded, instead of discarding them.
+	Strict bool
+	// Merge decodes onto the current values of the target without clobbering
+	// them, defaults are only applied to zero valued fields, which are also
+	// the only ones a `required` flag is enforced for.
+	Merge bool
+	// Redact receives the flag name and the decoded value of every field
+	// annotated with ",redact", right before the field is reset to its zero
+	// value. It is not called by Validate, which leaves the target untouched.
+	// Note that copies of the value (e.g. the command line arguments
+	// themselves) may still linger in memory.
+	Redact func(name string, value interface{})
+	// Rejected receives the flag name, the raw input and the error of every
+	// slice element and map entry discarded because it could not be decoded
+	// (never called on strict mode, where they fail instead).
+	Rejected func(name, raw string, err error)
+	// ExpandEnv expands the environment variable references (`$VAR` or
+	// `${VAR}`) of the provided values, like it is always done for defaults.
+	ExpandEnv bool
+	// UnusedEnvPrefix reports the environment variables starting with the
+	// given prefix which do not correspond to any field, through an
+	// UnusedEnvError returned once everything else was decoded.
+	UnusedEnvPrefix string
+	// ClearOnEmpty makes a flag provided with an empty value (e.g. `-proxy=`)
+	// reset its field to the zero value, instead of being treated as absent.
+	// Combined with Merge, it allows clearing a value set beforehand.
+	ClearOnEmpty bool
+	// MaxDepth limits the number of nested structs decoded, guarding against
+	// self-referential pointers to structs. DefaultMaxDepth when zero.
+	MaxDepth int
+	// Interpolate substitutes the `{{name}}` references to other flags of
+	// every value (e.g. `-url=https://{{host}}:{{port}}`) with their
+	// resolved values, once every flag was resolved. References to unknown
+	// flags are kept as they are.
+	Interpolate bool
+	// TemplateData is the data the values of the flags annotated with
+	// ",template" are rendered against, using text/template.
+	TemplateData interface{}
+	// DuplicateScalarIsError fails when a flag of a field other than a slice,
+	// an array or a map is provided more than once in the arguments, instead
+	// of using the first occurrence.
+	DuplicateScalarIsError bool
+	// PromptMissing asks for the value of the required flags not provided,
+	// writing a prompt to PromptOut and reading a line from PromptIn,
+	// instead of failing. The standard input is only prompted when it is a
+	// terminal.
+	PromptMissing bool
+	// PromptIn is the reader prompted values are read from, os.Stdin when
+	// nil.
+	PromptIn io.Reader
+	// PromptOut is the writer prompts are written to, os.Stdout when nil.
+	PromptOut io.Writer
+	// GOOS is the operating system the ",default-<goos>=value" defaults
+	// are selected for, runtime.GOOS when empty.
+	GOOS string
+	// Stdin is the reader the values of the flags annotated with ",stdin"
+	// are read from when provided as `-`, os.Stdin when nil.
+	Stdin io.Reader
+	// DefaultsFile is the path of a file of `name=value` lines (following
+	// the format of ReaderSource) holding defaults of the flags, which take
+	// precedence over the ",default=value" ones. The file is cached, and
+	// read again once its modification time changes, so long-lived
+	// processes decoding periodically pick up its changes.
+	DefaultsFile string
+	// Warnings is the writer the decoding errors of the flags annotated
+	// with ",onerror=warn-default" or ",onerror=warn-skip" are written to,
+	// os.Stderr when nil.
+	Warnings io.Writer
+
+	allowedBy    map[string]map[string][]string
+	masks        map[reflect.Type]map[string]uint64
+	types        map[reflect.Type]func(dst reflect.Value, raw string) error
+	parsers      map[string]func(raw string) (interface{}, error)
+	validators   map[string]func(string) error
+	transforms   map[string]func(string) string
+	funcs        map[reflect.Type]map[string]reflect.Value
+	schemes      map[string]func() Decoder
+	encoders     map[reflect.Type]func(v reflect.Value) (string, error)
+	defaultFuncs map[reflect.Type]map[string]func(v interface{}) string
+	presets      map[string]map[string]interface{}
+	boolWords    map[string]bool
+	// answers holds the prompted values of the batch applied by DecodeStream,
+	// so validating and decoding it prompts for each flag once.
+	answers map[string]string
 }
 
 // Decode command line arguments into the provided target.
@@ -51,12 +275,79 @@ func inSlice(values []string, target string) bool {
 // struct tag with a value containing the name of the command line argument.
 //
 // Default values may be provided by appending ",default=value" to the
-// struct tag.
+// struct tag, references to environment variables (`$VAR` or `${VAR}`) and
+// to the `$hostname`, `$pid`, `$user` and `$home` tokens in defaults are
+// expanded. Defaults for a single operating system may be
+// provided by appending ",default-<goos>=value" (e.g. ",default-windows=C:\logs"),
+// taking precedence over "default" on it.
 // Required values may be marked by appending ",required"
-// to the struct tag.  It is an error to provide both "default" and
-// "required".
+// to the struct tag, any of the configured sources of the parser (and not
+// only the arguments) satisfies them.  It is an error to provide both
+// "default" and "required".
+// Fields of type time.Time are parsed using the layout provided by
+// appending ",layout=value" to the struct tag, time.RFC3339 by default.
+// Several layouts separated by `|` are attempted in order.
 func Decode(v interface{}) error {
-	args := os.Args[1:]
+	return new(Parser).Decode(v)
+}
+
+// Validate performs the same parsing and validation as Decode, but over a
+// copy of the provided target, leaving its current values untouched. Files
+// of `*os.File` fields are only checked, without being opened.
+// It is useful to check whether the command line arguments would decode
+// cleanly before applying them.
+func Validate(v interface{}) error {
+	return new(Parser).Validate(v)
+}
+
+// DecodeWithDefaults decodes command line arguments into the provided
+// target like Decode does, taking the value of every field whose flag is
+// absent and has no tag default from the defaults struct, which must be of
+// the same type as the target (or a pointer to it).
+func DecodeWithDefaults(v interface{}, defaults interface{}) error {
+	return new(Parser).DecodeWithDefaults(v, defaults)
+}
+
+// DecodeAll decodes the same arguments into each of the provided targets in
+// turn, every one of them following the same rules as Decode. The errors of
+// all the targets are aggregated into Errors.
+func DecodeAll(args []string, targets ...interface{}) error {
+	return (&Parser{Args: args}).DecodeAll(targets...)
+}
+
+// Decode command line arguments into the provided target, following the
+// same rules as the package level Decode function.
+func (p *Parser) Decode(v interface{}) error {
+	return p.decodeTarget(v, reflect.Value{}, nil, false)
+}
+
+// DecodeAll decodes command line arguments into each of the provided
+// targets, following the same rules as the package level DecodeAll function.
+func (p *Parser) DecodeAll(targets ...interface{}) error {
+	var errs Errors
+	for _, v := range targets {
+		if err := p.Decode(v); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// DecodeWithDefaults decodes command line arguments into the provided
+// target, following the same rules as the package level DecodeWithDefaults
+// function.
+func (p *Parser) DecodeWithDefaults(v interface{}, defaults interface{}) error {
+	dv := reflect.Indirect(reflect.ValueOf(defaults))
+	if vl := reflect.ValueOf(v); vl.Kind() == reflect.Ptr && dv.IsValid() && dv.Type() != vl.Type().Elem() {
+		return fmt.Errorf("flagstruct: defaults of type `%v` do not match the target type `%v`", dv.Type(), vl.Type().Elem())
+	}
+	return p.decodeTarget(v, dv, nil, false)
+}
+
+func (p *Parser) decodeTarget(v interface{}, defaults reflect.Value, report map[string][]Provided, validating bool) error {
 	vl := reflect.ValueOf(v)
 	if vl.Kind() != reflect.Ptr || vl.IsNil() {
 		return ErrInvalidType
@@ -65,135 +356,1276 @@ func Decode(v interface{}) error {
 	if vl.Kind() != reflect.Struct {
 		return ErrInvalidType
 	}
+	args := p.Args
+	if args == nil {
+		args = os.Args[1:]
+	}
+	if p.PrefixFilter != "" {
+		args = filterPrefix(args, p.PrefixFilter)
+	}
+	fileDefaults, err := p.fileDefaults()
+	if err != nil {
+		return err
+	}
+	s := &state{args: args, values: make(map[string]string), envNames: make(map[string]bool), report: report, defaults: fileDefaults, validating: validating}
+	if p.Interpolate {
+		dry := &state{args: args, values: make(map[string]string), envNames: make(map[string]bool), dry: true, defaults: fileDefaults}
+		if err := p.decode(dry, vl, defaults, ""); err != nil {
+			return err
+		}
+		interpolated, err := interpolate(dry.values)
+		if err != nil {
+			return err
+		}
+		s.interpolated = interpolated
+	}
+	if err := p.decode(s, vl, defaults, ""); err != nil {
+		return err
+	}
+	if err := s.setPendingRaw(); err != nil {
+		return err
+	}
+	if len(s.missing) > 0 {
+		return &MissingError{Names: s.missing}
+	}
+	if err := p.checkAllowedBy(s); err != nil {
+		return err
+	}
+	return p.checkUnusedEnv(s)
+}
+
+// maxDepth returns the configured MaxDepth, or DefaultMaxDepth.
+func (p *Parser) maxDepth() int {
+	if p.MaxDepth > 0 {
+		return p.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+// state holds the data shared across a single decoding process.
+type state struct {
+	args []string
+	// values holds the resolved value of every decoded flag.
+	values map[string]string
+	// controlled holds the annotations whose allowed values depend on the
+	// value of another flag.
+	controlled []*annotation
+	// envNames holds the environment variable names of every decoded flag.
+	envNames map[string]bool
+	// allowedFiles holds the allowed values read from every `allowed-file`.
+	allowedFiles map[string][]string
+	// depth is the number of nested structs being decoded.
+	depth int
+	// missing holds the names of the required flags not provided.
+	missing []string
+	// dry holds whether the fields are only resolved, without being
+	// validated nor decoded, to interpolate their values afterwards.
+	dry bool
+	// interpolated holds the values of every flag, once interpolated.
+	interpolated map[string]string
+	// prompt holds the reader prompted values are read from.
+	prompt *bufio.Reader
+	// report holds every value provided for each flag, when requested.
+	report map[string][]Provided
+	// defaults holds the defaults read from Parser.DefaultsFile.
+	defaults MapSource
+	// preset holds whether the struct being decoded was filled from a
+	// preset, whose values are kept like merged ones.
+	preset bool
+	// raw holds the value of every flag as provided, before being expanded,
+	// transformed or defaulted.
+	raw map[string]string
+	// pendingRaw holds the raw fields whose flag was not resolved yet when
+	// the struct holding them was decoded.
+	pendingRaw []rawField
+	// validating holds whether the target is a copy being validated, where
+	// side effects like opening files are skipped.
+	validating bool
+}
+
+// Validate performs the same parsing and validation as Decode, but over a
+// copy of the provided target, leaving its current values untouched. Files
+// of `*os.File` fields are only checked, without being opened.
+func (p *Parser) Validate(v interface{}) error {
+	vl := reflect.ValueOf(v)
+	if vl.Kind() != reflect.Ptr || vl.IsNil() || vl.Elem().Kind() != reflect.Struct {
+		return ErrInvalidType
+	}
+	return p.decodeTarget(clone(vl, p.maxDepth()+1).Interface(), reflect.Value{}, nil, true)
+}
+
+// decode decodes the fields of the struct vl, the defaults struct, when
+// valid, holds the values of the fields without a flag nor a tag default.
+// The prefix is prepended to the flag name of every field.
+func (p *Parser) decode(s *state, vl, defaults reflect.Value, prefix string) error {
+	if s.depth++; s.depth > p.maxDepth() {
+		return fmt.Errorf("flagstruct: maximum depth of %d nested structs exceeded at `%v`", p.maxDepth(), vl.Type())
+	}
+	defer func() { s.depth-- }()
 	t := vl.Type()
+	var raws []rawField
 	for i := 0; i < vl.NumField(); i++ {
 		ft := t.Field(i)
 		if ft.PkgPath != "" {
 			continue
 		}
 		f := vl.Field(i)
+		var d reflect.Value
+		if defaults.IsValid() {
+			d = defaults.Field(i)
+		}
+		nested := false
 		switch f.Kind() {
 		case reflect.Ptr:
-			if f.Elem().Kind() != reflect.Struct {
+			if f.Elem().Kind() != reflect.Struct || isValueStruct(f.Elem().Type()) || p.registered(f.Type()) {
 				break
 			}
 			f = f.Elem()
+			if d.IsValid() {
+				d = reflect.Indirect(d)
+			}
 			fallthrough
 		case reflect.Struct:
+			if isValueStruct(f.Type()) {
+				break
+			}
 			if !f.Addr().CanInterface() {
 				continue
 			}
-			ss := f.Addr().Interface()
-			_, custom := ss.(Decoder)
-			if custom {
+			if isCustom(f.Addr()) || p.registered(f.Type()) || tagOption(ft.Tag.Get("flag"), "encoding") != "" {
+				break
+			}
+			if tagOption(ft.Tag.Get("flag"), "preset") != "" {
+				if err := p.decodePreset(s, f, d, ft, prefix); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := p.decode(s, f, d, prefix+tagPrefix(ft.Tag.Get("flag"))); err != nil {
+				return err
+			}
+			nested = true
+		case reflect.Interface:
+			if f.IsNil() {
+				break
+			}
+			e := f.Elem()
+			if e.Kind() != reflect.Ptr || e.IsNil() || e.Elem().Kind() != reflect.Struct {
 				break
 			}
-			if err := Decode(ss); err != nil {
+			if isCustom(e) {
+				break
+			}
+			if err := p.decode(s, e.Elem(), reflect.Value{}, prefix+tagPrefix(ft.Tag.Get("flag"))); err != nil {
 				return err
 			}
+			continue
 		}
 		if !f.CanSet() {
 			continue
 		}
-		tag := ft.Tag.Get("flag")
-		if tag == "" {
+		tag := p.tag(ft, !nested)
+		if tag == "" || (nested && strings.HasPrefix(tag, ",")) {
 			continue
 		}
-		flagVal, err := parse(args, tag)
+		a, err := parseAnnotation(tag)
 		if err != nil {
 			return err
 		}
-		if flagVal == "" {
+		a.name = prefix + a.name
+		if p.NameFunc != nil {
+			a.name = p.NameFunc(ft.Name, a.name)
+		}
+		if _, ok := p.parsers[a.parser]; a.parser != "" && !ok {
+			return fmt.Errorf("flagstruct: malformed annotation, no parser registered as `%s` for flag '%s'", a.parser, a.name)
+		}
+		if _, ok := p.validators[a.validate]; a.validate != "" && !ok {
+			return fmt.Errorf("flagstruct: malformed annotation, no validator registered as `%s` for flag '%s'", a.validate, a.name)
+		}
+		if a.raw {
+			if f.Kind() != reflect.String {
+				return fmt.Errorf("flagstruct: option `raw` of flag '%s' requires a string field", a.name)
+			}
+			raws = append(raws, rawField{f, a.name})
 			continue
 		}
-		decoder, custom := f.Addr().Interface().(Decoder)
-		var decodeErr error
+		if v, ok := a.osDefaults[p.goos()]; ok {
+			a.hasDefault, a.defaultValue = true, v
+		}
+		if a.runes && (f.Kind() != reflect.Slice || f.Type().Elem().Kind() != reflect.Int32) {
+			return fmt.Errorf("flagstruct: option `runes` of flag '%s' requires a []rune field", a.name)
+		}
+		a.list = f.Kind() == reflect.Slice && !a.runes && f.Type() != ipType && f.Type().Elem().Kind() != reflect.Uint8
+		a.mapped = f.Kind() == reflect.Map && f.Type() != urlValuesType
+		a.integer = isInteger(f.Kind()) || (a.list && isInteger(f.Type().Elem().Kind()))
+		if m := f.Type(); a.mapped || (a.list && m.Elem().Kind() == reflect.Map) {
+			if a.list {
+				m = m.Elem()
+			}
+			a.mapped = true
+			a.integer = isInteger(m.Elem().Kind()) || (m.Elem().Kind() == reflect.Struct && isInteger(m.Key().Kind()))
+		}
+		a.boolean = f.Kind() == reflect.Bool || (f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.Bool)
+		a.bytes = f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Uint8
+		a.boolWords = p.boolWords
+		a.validating = s.validating
+		merged := (p.Merge || s.preset) && !isZero(f)
+		if merged {
+			a.required, a.hasDefault, a.defaultValue = false, false, ""
+		}
+		if a.allowedFile != "" {
+			if a.allowed, err = s.allowedValues(a.allowedFile); err != nil {
+				return fmt.Errorf("flagstruct: could not read the allowed values of flag '%s': %v", a.name, err)
+			}
+			a.hasAllowed = true
+		}
+		flagVal, err := p.resolve(s, a)
+		if s.dry {
+			s.values[a.name] = flagVal
+			continue
+		}
+		if m, ok := err.(*MissingError); ok {
+			s.missing = append(s.missing, m.Names...)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkCount(a, flagVal); err != nil {
+			return err
+		}
+		if p.DuplicateScalarIsError && !repeatable(f.Kind()) && len(a.occurrences) > 1 {
+			return fmt.Errorf("flagstruct: flag '%s' is provided %d times, expected once", a.name, len(a.occurrences))
+		}
+		if flagVal != "" && a.validate != "" {
+			if err := p.validate(flagVal, a); err != nil {
+				return err
+			}
+		}
+		s.values[a.name] = flagVal
+		for _, name := range a.envNames() {
+			s.envNames[name] = true
+		}
+		if a.allowedBy != "" {
+			s.controlled = append(s.controlled, a)
+		}
 		switch {
-		case custom:
-			decodeErr = decoder.Decode(flagVal)
-		case f.Kind() == reflect.Slice:
-			decodeSlice(&f, flagVal)
-		default:
-			decodeErr = decodePrimitive(&f, flagVal)
+		case flagVal != "":
+			if err := p.decodeField(&f, flagVal, a); err != nil {
+				if err = p.onError(&f, a, err); err != nil {
+					return err
+				}
+			}
+		case p.cleared(flagVal, a):
+			f.Set(reflect.Zero(f.Type()))
+		case d.IsValid() && !nested && !merged:
+			f.Set(d)
 		}
-		if decodeErr != nil {
-			return fmt.Errorf("flagstruct: could not decode value `%s` to kind `%v`: %v", flagVal, f.Kind(), decodeErr)
+		if err := checkDecoded(&f, a); err != nil {
+			return err
+		}
+		if a.redact {
+			if err := p.redact(&f, a); err != nil {
+				return err
+			}
 		}
 	}
+	if !s.dry {
+		s.setRaw(raws)
+	}
+	if len(p.defaultFuncs) != 0 && len(s.missing) == 0 && !s.dry {
+		if err := p.computeDefaults(vl, prefix); err != nil {
+			return err
+		}
+	}
+	if h, ok := vl.Addr().Interface().(AfterDecoder); ok && len(s.missing) == 0 && !s.dry {
+		return h.AfterDecode()
+	}
+	return nil
+}
+
+// decodeField validates and decodes flagVal into f, according to its type
+// and annotation.
+func (p *Parser) decodeField(f *reflect.Value, flagVal string, a *annotation) error {
+	if a.piped && a.bytes {
+		f.SetBytes([]byte(flagVal))
+		return nil
+	}
+	if f.Kind() == reflect.String {
+		if err := checkBounds(a, flagVal); err != nil {
+			return err
+		}
+	}
+	decoder, custom := f.Addr().Interface().(Decoder)
+	if f.Kind() == reflect.Interface && !f.IsNil() {
+		decoder, custom = f.Interface().(Decoder)
+	}
+	setter, typed := f.Addr().Interface().(Setter)
+	contextDecoder, contextual := f.Addr().Interface().(ContextDecoder)
+	var decodeErr error
+	switch {
+	case a.parser != "":
+		decodeErr = p.decodeParsed(f, flagVal, a)
+	case a.function:
+		decodeErr = p.decodeFunc(f, flagVal)
+	case f.Kind() == reflect.Interface && f.Type().NumMethod() != 0 && len(p.schemes) != 0:
+		decodeErr = p.decodeScheme(f, flagVal)
+	case a.setter != "":
+		decodeErr = decodeSetter(f, flagVal, a)
+	case a.encoding != "":
+		if decodeErr = decodeEncoded(f, flagVal); decodeErr == nil {
+			return p.decodeOmitted(f, flagVal)
+		}
+	case contextual:
+		decodeErr = decodeContext(contextDecoder, flagVal, a)
+		if _, timeout := decodeErr.(*timeoutError); timeout {
+			return decodeErr
+		}
+	case custom:
+		decodeErr = decoder.Decode(flagVal)
+	case typed:
+		decodeErr = setter.SetFromFlag(inferValue(flagVal))
+	case p.registered(f.Type()):
+		decodeErr = p.types[f.Type()](*f, flagVal)
+	case a.mask != "":
+		decodeErr = p.decodeMask(f, flagVal, a)
+	case a.runes:
+		f.Set(reflect.ValueOf([]rune(flagVal)).Convert(f.Type()))
+	case f.Type() == urlValuesType:
+		decodeErr = decodeQuery(f, flagVal)
+	case f.Type() == ipType:
+		decodeErr = decodeValue(f, flagVal, a)
+	case f.Type() == reflect.PtrTo(syncMapType):
+		decodeErr = p.decodeSyncMap(f, flagVal, a)
+	case f.Kind() == reflect.Slice && len(a.positions) > 0:
+		decodeErr = decodePositions(f, flagVal, a)
+	case f.Kind() == reflect.Slice:
+		if decodeErr = p.decodeSlice(f, flagVal, a); decodeErr == nil {
+			decodeErr = normalizeSlice(f, a)
+		}
+	case f.Kind() == reflect.Array:
+		decodeErr = p.decodeArray(f, flagVal, a)
+	case f.Kind() == reflect.Map:
+		decodeErr = p.decodeMap(f, flagVal, a)
+	default:
+		decodeErr = decodeValue(f, flagVal, a)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("flagstruct: could not decode value `%s` to kind `%v`: %w", flagVal, f.Kind(), decodeErr)
+	}
 	return nil
 }
 
-func parse(args []string, tag string) (string, error) {
+// repeatable reports whether fields of kind k collect every occurrence of
+// their flag, instead of using the first one.
+func repeatable(k reflect.Kind) bool {
+	return k == reflect.Slice || k == reflect.Array || k == reflect.Map
+}
+
+// checkCount validates the number of occurrences of the flag in the
+// arguments against the `mincount` and `maxcount` options. A value resolved
+// from any other source (e.g. an environment variable or a default) counts
+// as a single occurrence.
+func checkCount(a *annotation, flagVal string) error {
+	n := len(a.occurrences)
+	if n == 0 && flagVal != "" {
+		n = 1
+	}
+	if a.minCount > 0 && n < a.minCount {
+		return fmt.Errorf("flagstruct: flag '%s' must be provided at least %s, got %d", a.name, times(a.minCount), n)
+	}
+	if a.maxCount > 0 && n > a.maxCount {
+		return fmt.Errorf("flagstruct: flag '%s' must be provided at most %s, got %d", a.name, times(a.maxCount), n)
+	}
+	return nil
+}
+
+// times returns the number of times n, in singular when it is 1.
+func times(n int) string {
+	if n == 1 {
+		return "once"
+	}
+	return fmt.Sprintf("%d times", n)
+}
+
+// checkDecoded validates the final value of f, no matter whether it comes
+// from a flag, a default or was already there.
+func checkDecoded(f *reflect.Value, a *annotation) error {
+	if a.nonzero && isZero(*f) {
+		return fmt.Errorf("flagstruct: flag '%s' must not be a zero value", a.name)
+	}
+	return nil
+}
+
+// redact hands the value of f to the Redact callback, resetting the field to
+// its zero value afterwards. The callback is skipped while validating.
+func (p *Parser) redact(f *reflect.Value, a *annotation) error {
+	if p.Redact == nil {
+		return fmt.Errorf("flagstruct: flag '%s' is annotated with `redact` but no Redact callback is configured", a.name)
+	}
+	if isZero(*f) || a.validating {
+		return nil
+	}
+	p.Redact(a.name, f.Interface())
+	f.Set(reflect.Zero(f.Type()))
+	return nil
+}
+
+// tagPrefix returns the value of the `prefix` option of a struct tag, which
+// nested structs prepend to the flag names of their fields.
+func tagPrefix(tag string) string {
+	return tagOption(tag, "prefix")
+}
+
+// tagOption returns the value of the given option of a struct tag.
+func tagOption(tag, name string) string {
+	for _, o := range strings.Split(tag, ",")[1:] {
+		if strings.HasPrefix(o, name+"=") {
+			return o[len(name)+1:]
+		}
+	}
+	return ""
+}
+
+// tag returns the annotation of the given field, falling back to the name
+// declared in its `json` struct tag, or inferred from the field name when
+// enabled. Names are never inferred for nested structs.
+func (p *Parser) tag(ft reflect.StructField, infer bool) string {
+	if tag := ft.Tag.Get("flag"); tag != "" {
+		return tag
+	}
+	if p.JSONFallback {
+		name := strings.Split(ft.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	if p.InferNames && infer {
+		return p.NameStyle.infer(ft.Name)
+	}
+	return ""
+}
+
+// clone returns a pointer to a copy of the struct pointed by v, nested
+// pointers to structs (including the ones held by interfaces) are copied as
+// well, so decoding the copy never reaches the original values. Pointers
+// deeper than the given depth are shared, which keeps self-referential
+// structs from being copied forever.
+func clone(v reflect.Value, depth int) reflect.Value {
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+	vl := c.Elem()
+	if depth <= 1 {
+		return c
+	}
+	for i := 0; i < vl.NumField(); i++ {
+		f := vl.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.Ptr:
+			if !f.IsNil() && f.Elem().Kind() == reflect.Struct && f.Elem().Type() != syncMapType {
+				f.Set(clone(f, depth-1))
+			}
+		case reflect.Struct:
+			f.Set(clone(f.Addr(), depth-1).Elem())
+		case reflect.Interface:
+			if e := f.Elem(); e.Kind() == reflect.Ptr && !e.IsNil() && e.Elem().Kind() == reflect.Struct {
+				f.Set(clone(e, depth-1))
+			}
+		}
+	}
+	return c
+}
+
+// annotation holds the options declared through the `flag` struct tag.
+type annotation struct {
+	name         string
+	short        string
+	required     bool
+	hasDefault   bool
+	defaultValue string
+	osDefaults   map[string]string
+	hasAllowed   bool
+	allowed      []string
+	layout       string
+	gte          string
+	lte          string
+	semver       bool
+	env          string
+	allowedBy    string
+	nonzero      bool
+	timeout      time.Duration
+	redact       bool
+	sep          string
+	innerSep     string
+	allowedFile  string
+	positions    []string
+	percent      bool
+	rate         bool
+	stdin        bool
+	preset       string
+	raw          bool
+	runes        bool
+	iso8601      bool
+	grouped      string
+	base         int
+	byteOrder    string
+	stringer     int
+	parser       string
+	validate     string
+	pipe         []string
+	setter       string
+	encoding     string
+	minCount     int
+	maxCount     int
+	template     bool
+	function     bool
+	keyType      string
+	defaultUnit  string
+	valueType    string
+	pad          bool
+	truncate     bool
+	// integer holds whether the field (or the elements of the list) is of
+	// an integer kind, where allowed values may be ranges.
+	integer   bool
+	dedupe    bool
+	onError   string
+	sort      bool
+	mask      string
+	fileFlags int
+	perm      os.FileMode
+	// list holds whether the field is a slice of elements, where allowed
+	// values apply to every element.
+	list bool
+	// mapped holds whether the field is a map (or a slice of maps), where
+	// allowed values apply to the value of every entry (or to the keys of
+	// sets).
+	mapped bool
+	// boolean holds whether the field is a bool (or a pointer to one), which
+	// may be provided as a bare flag.
+	boolean bool
+	// boolWords holds the registered words decoded as true or false.
+	boolWords map[string]bool
+	// validating holds whether the decoding only validates the value.
+	validating bool
+	// occurrences holds every value of the flag found in the arguments.
+	occurrences []string
+	// bytes holds whether the field is a byte slice.
+	bytes bool
+	// piped holds whether the value is the content of the standard input.
+	piped bool
+}
+
+// newAnnotation returns the annotation of the given flag name, holding the
+// default options.
+func newAnnotation(name string) *annotation {
+	return &annotation{
+		name:      name,
+		layout:    time.RFC3339,
+		sep:       ";",
+		keyType:   "string",
+		valueType: "string",
+		innerSep:  ",",
+		fileFlags: defaultFileFlags,
+		perm:      defaultFilePerm,
+	}
+}
+
+func parseAnnotation(tag string) (*annotation, error) {
 	parts := strings.Split(tag, ",")
 	if parts[0] == "" {
-		return "", errors.New("flagstruct: malformed annotation, `flag` name must be defined")
-	}
-	flagVal := lookup(args, parts[0])
-	if len(parts) < 2 {
-		return flagVal, nil
+		return nil, errors.New("flagstruct: malformed annotation, `flag` name must be defined")
 	}
-	var required, hasDefault, hasAllowed bool
-	var defaultValue, allowedValue string
+	a := newAnnotation(parts[0])
+	// conditional holds whether the `required-env` condition holds, which
+	// is not in conflict with a default.
+	var conditional bool
 	for _, o := range parts[1:] {
-		if !required {
-			required = strings.HasPrefix(o, "required")
+		if strings.HasPrefix(o, "required-env=") {
+			kv := strings.SplitN(o[13:], "=", 2)
+			if len(kv) < 2 || kv[0] == "" {
+				return nil, fmt.Errorf("flagstruct: malformed annotation, invalid required-env `%s`, expected `VAR=value`", o[13:])
+			}
+			conditional = os.Getenv(kv[0]) == kv[1]
+			continue
+		}
+		if !a.required {
+			a.required = strings.HasPrefix(o, "required")
 		}
 		if strings.HasPrefix(o, "default=") {
-			hasDefault = true
-			defaultValue = o[8:]
+			a.hasDefault = true
+			a.defaultValue = o[8:]
+		}
+		if kv := strings.SplitN(o, "=", 2); len(kv) == 2 && strings.HasPrefix(kv[0], "default-") {
+			if a.osDefaults == nil {
+				a.osDefaults = make(map[string]string)
+			}
+			a.osDefaults[kv[0][8:]] = kv[1]
 		}
 		if strings.HasPrefix(o, "allowed=") {
-			hasAllowed = true
-			allowedValue = o[8:]
+			a.hasAllowed = true
+			a.allowed = strings.Split(o[8:], ";")
+		}
+		if strings.HasPrefix(o, "allowed-file=") {
+			a.allowedFile = o[13:]
+		}
+		if strings.HasPrefix(o, "layout=") {
+			a.layout = o[7:]
+		}
+		if strings.HasPrefix(o, "short=") {
+			a.short = o[6:]
+		}
+		if strings.HasPrefix(o, "gte=") {
+			a.gte = o[4:]
+		}
+		if strings.HasPrefix(o, "lte=") {
+			a.lte = o[4:]
+		}
+		if o == "semver" {
+			a.semver = true
+		}
+		if o == "nonzero" {
+			a.nonzero = true
+		}
+		if o == "redact" {
+			a.redact = true
+		}
+		if o == "pad" {
+			a.pad = true
+		}
+		if o == "truncate" {
+			a.truncate = true
+		}
+		if o == "template" {
+			a.template = true
+		}
+		if strings.HasPrefix(o, "defaultunit=") {
+			a.defaultUnit = o[12:]
+			if _, err := time.ParseDuration("1" + a.defaultUnit); err != nil {
+				return nil, fmt.Errorf("flagstruct: malformed annotation, invalid defaultunit `%s`", a.defaultUnit)
+			}
+		}
+		if strings.HasPrefix(o, "keytype=") {
+			a.keyType = o[8:]
+		}
+		if strings.HasPrefix(o, "valuetype=") {
+			a.valueType = o[10:]
+		}
+		if o == "func" {
+			a.function = true
+		}
+		if strings.HasPrefix(o, "mincount=") || strings.HasPrefix(o, "maxcount=") {
+			n, err := strconv.Atoi(o[9:])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("flagstruct: malformed annotation, invalid %s `%s`", o[:8], o[9:])
+			}
+			if o[:3] == "min" {
+				a.minCount = n
+			} else {
+				a.maxCount = n
+			}
+		}
+		if strings.HasPrefix(o, "encoding=") {
+			if a.encoding = o[9:]; a.encoding != "json" {
+				return nil, fmt.Errorf("flagstruct: malformed annotation, unknown encoding `%s`", a.encoding)
+			}
+		}
+		if strings.HasPrefix(o, "setter=") {
+			a.setter = o[7:]
+		}
+		if strings.HasPrefix(o, "validate=") {
+			a.validate = o[9:]
+		}
+		if strings.HasPrefix(o, "onerror=") {
+			a.onError = o[8:]
+			if a.onError != onErrorFail && a.onError != onErrorWarnDefault && a.onError != onErrorWarnSkip {
+				return nil, fmt.Errorf("flagstruct: malformed annotation, unknown onerror policy `%s`", a.onError)
+			}
+		}
+		if strings.HasPrefix(o, "pipe=") {
+			a.pipe = strings.Split(o[5:], "|")
+		}
+		if strings.HasPrefix(o, "parser=") {
+			a.parser = o[7:]
+		}
+		if o == "stringer" {
+			a.stringer = defaultStringerBound
+		}
+		if strings.HasPrefix(o, "stringer=") {
+			bound, err := strconv.Atoi(o[9:])
+			if err != nil || bound < 1 {
+				return nil, fmt.Errorf("flagstruct: malformed annotation, invalid stringer bound `%s`", o[9:])
+			}
+			a.stringer = bound
+		}
+		if strings.HasPrefix(o, "base=") {
+			base, err := strconv.Atoi(o[5:])
+			if err != nil || base < 2 || base > 36 {
+				return nil, fmt.Errorf("flagstruct: malformed annotation, invalid base `%s`, expected a number between 2 and 36", o[5:])
+			}
+			a.base = base
+		}
+		if strings.HasPrefix(o, "byteorder=") {
+			if a.byteOrder = o[10:]; a.byteOrder != "le" && a.byteOrder != "be" {
+				return nil, fmt.Errorf("flagstruct: malformed annotation, unknown byte order `%s`, expected `le` or `be`", a.byteOrder)
+			}
+		}
+		if o == "grouped" {
+			a.grouped = ","
+		}
+		if strings.HasPrefix(o, "grouped=") && len(o) > 8 {
+			a.grouped = o[8:]
+		}
+		if o == "iso8601" {
+			a.iso8601 = true
+		}
+		if o == "percent" {
+			a.percent = true
+		}
+		if o == "rate" {
+			a.rate = true
+		}
+		if o == "stdin" {
+			a.stdin = true
+		}
+		if strings.HasPrefix(o, "preset=") && len(o) > 7 {
+			a.preset = o[7:]
+		}
+		if o == "raw" {
+			a.raw = true
+		}
+		if o == "runes" {
+			a.runes = true
+		}
+		// `dedupe-ordered` is an alias of `dedupe`, which already keeps
+		// the provided order of the first occurrences
+		if o == "dedupe" || o == "dedupe-ordered" {
+			a.dedupe = true
+		}
+		if o == "sort" {
+			a.sort = true
+		}
+		if o == "mask" {
+			a.mask = "|"
+		}
+		if strings.HasPrefix(o, "mask=") && len(o) > 5 {
+			a.mask = o[5:]
+		}
+		if strings.HasPrefix(o, "sep=") && len(o) > 4 {
+			a.sep = o[4:]
+		}
+		if strings.HasPrefix(o, "innersep=") && len(o) > 9 {
+			a.innerSep = o[9:]
+		}
+		if strings.HasPrefix(o, "positions=") {
+			a.positions = strings.Split(o[10:], ";")
+		}
+		if strings.HasPrefix(o, "timeout=") {
+			d, err := time.ParseDuration(o[8:])
+			if err != nil {
+				return nil, fmt.Errorf("flagstruct: malformed annotation, invalid timeout `%s`", o[8:])
+			}
+			a.timeout = d
+		}
+		if strings.HasPrefix(o, "fileflags=") {
+			flags, err := parseFileFlags(o[10:])
+			if err != nil {
+				return nil, err
+			}
+			a.fileFlags = flags
+		}
+		if strings.HasPrefix(o, "perm=") {
+			perm, err := parseFilePerm(o[5:])
+			if err != nil {
+				return nil, err
+			}
+			a.perm = perm
+		}
+		if strings.HasPrefix(o, "env=") {
+			a.env = o[4:]
+		}
+		if strings.HasPrefix(o, "allowedby=") {
+			a.allowedBy = o[10:]
+		}
+	}
+	if a.byteOrder != "" && a.base != 16 {
+		return nil, errors.New("flagstruct: malformed annotation, `byteorder` requires `base=16`")
+	}
+	if a.required && (a.hasDefault || len(a.osDefaults) != 0) {
+		return nil, ErrInvalidAnnotation
+	}
+	a.required = a.required || conditional
+	return a, nil
+}
+
+func (p *Parser) resolve(s *state, a *annotation) (string, error) {
+	flagVal := p.lookup(s.args, a)
+	if s.report != nil && !s.dry {
+		s.report[a.name] = p.provided(s.args, a)
+	}
+	if a.stdin && flagVal == "-" {
+		var err error
+		if flagVal, err = p.stdinValue(a); err != nil {
+			return "", err
 		}
 	}
-	if required && hasDefault {
-		return "", ErrInvalidAnnotation
+	if flagVal == "" && a.required && !s.dry && p.PromptMissing {
+		flagVal = p.prompt(s, a)
 	}
-	if flagVal == "" && required {
-		return "", fmt.Errorf(`flagstruct: flag '%s' is missing`, parts[0])
+	if !s.dry {
+		s.recordRaw(a.name, flagVal)
+	}
+	if flagVal == "" && a.required && !s.dry {
+		return "", &MissingError{Names: []string{a.name}}
+	}
+	if flagVal != "" && p.ExpandEnv {
+		flagVal = os.ExpandEnv(flagVal)
+	}
+	if p.cleared(flagVal, a) {
+		return "", nil
+	}
+	if v, ok := s.defaults[a.name]; ok && flagVal == "" {
+		flagVal = v
 	}
 	if flagVal == "" {
-		flagVal = defaultValue
+		flagVal = expandDefault(a.defaultValue)
+	}
+	if s.dry {
+		return flagVal, nil
+	}
+	if v, ok := s.interpolated[a.name]; ok {
+		flagVal = v
 	}
-	if parts := strings.Split(allowedValue, ";"); flagVal != "" && hasAllowed && len(parts) != 0 {
-		if !inSlice(parts, flagVal) {
-			return "", fmt.Errorf("flagstruct: the provided value is not allowed, instead use %+v", parts)
+	if flagVal != "" && a.template {
+		var err error
+		if flagVal, err = p.render(flagVal, a); err != nil {
+			return "", err
+		}
+	}
+	if flagVal != "" && len(a.pipe) != 0 {
+		var err error
+		if flagVal, err = p.transform(flagVal, a); err != nil {
+			return "", err
+		}
+	}
+	if flagVal != "" && a.hasAllowed && len(a.allowed) != 0 {
+		if a.mapped {
+			if keys, value := a.disallowedEntries(flagVal); len(keys) != 0 {
+				return "", fmt.Errorf("flagstruct: the entries of keys %+v of flag '%s' are not allowed, instead use %+v%s", keys, a.name, a.allowed, a.suggestion(value))
+			}
+		} else if a.list {
+			if e, ok := a.disallowedElement(flagVal); ok {
+				return "", fmt.Errorf("flagstruct: the element `%s` of flag '%s' is not allowed, instead use %+v%s", e, a.name, a.allowed, a.suggestion(e))
+			}
+		} else if !a.isAllowed(flagVal) {
+			return "", fmt.Errorf("flagstruct: the provided value is not allowed, instead use %+v%s", a.allowed, a.suggestion(flagVal))
 		}
 	}
 	return flagVal, nil
 }
 
-func decodeSlice(f *reflect.Value, flagVal string) {
+// goos returns the operating system the defaults are selected for.
+func (p *Parser) goos() string {
+	if p.GOOS != "" {
+		return p.GOOS
+	}
+	return runtime.GOOS
+}
+
+// cleared reports whether the flag was explicitly provided with an empty
+// value, which resets the field when ClearOnEmpty is enabled.
+func (p *Parser) cleared(flagVal string, a *annotation) bool {
+	return p.ClearOnEmpty && flagVal == "" && len(a.occurrences) > 0
+}
+
+// lookup returns the value of the flag from the last source holding one,
+// when sources are provided, otherwise from the first origin of the
+// precedence list holding one.
+func (p *Parser) lookup(args []string, a *annotation) string {
+	for _, src := range p.sources() {
+		flagVal, ok := lookupSource(src, args, a)
+		if _, isArgs := src.(argsSource); ok && isArgs && p.ClearOnEmpty {
+			return flagVal
+		}
+		if flagVal != "" {
+			return flagVal
+		}
+	}
+	return ""
+}
+
+// sources returns the sources flags are resolved from, by precedence.
+func (p *Parser) sources() []Source {
+	sources := make([]Source, len(p.Sources))
+	for i, src := range p.Sources {
+		sources[len(sources)-1-i] = src
+	}
+	if len(sources) == 0 {
+		precedence := p.Precedence
+		if len(precedence) == 0 {
+			precedence = []Origin{OriginArgs}
+		}
+		for _, o := range precedence {
+			switch o {
+			case OriginArgs:
+				sources = append(sources, argsSource{})
+			case OriginEnv:
+				sources = append(sources, envSource{})
+			}
+		}
+	}
+	return sources
+}
+
+// checkBounds validates flagVal against the `gte` and `lte` options of the
+// annotation, comparing lexicographically or as semantic versions.
+func checkBounds(a *annotation, flagVal string) error {
+	compare := func(x, y string) (int, error) {
+		return strings.Compare(x, y), nil
+	}
+	if a.semver {
+		compare = compareSemver
+	}
+	if a.gte != "" {
+		c, err := compare(flagVal, a.gte)
+		if err != nil {
+			return err
+		}
+		if c < 0 {
+			return fmt.Errorf("flagstruct: flag '%s' must be greater than or equal to `%s`, got `%s`", a.name, a.gte, flagVal)
+		}
+	}
+	if a.lte != "" {
+		c, err := compare(flagVal, a.lte)
+		if err != nil {
+			return err
+		}
+		if c > 0 {
+			return fmt.Errorf("flagstruct: flag '%s' must be lower than or equal to `%s`, got `%s`", a.name, a.lte, flagVal)
+		}
+	}
+	return nil
+}
+
+// decodeSlice decodes values separated by semicolon (`;`), or the `sep`
+// option, against the slice's element type, collecting the values of
+// repeated flags. Values that could not be decoded are discarded, unless
+// the parser is strict. Slices of slices are decoded splitting every
+// element by comma (`,`), or the `innersep` option.
+func (p *Parser) decodeSlice(f *reflect.Value, flagVal string, a *annotation) error {
 	var values []string
-	var toReduce int
-	parts := strings.Split(flagVal, ";")
-	for _, x := range parts {
+	for _, x := range sliceElements(f.Type(), flagVal, a) {
 		if x != "" {
-			values = append(values, strings.TrimSpace(x))
+			values = append(values, unquote(strings.TrimSpace(x)))
 		}
 	}
-	length := len(values)
-	slice := reflect.MakeSlice(f.Type(), length, length)
-	if length > 0 {
-		for i := 0; i < length; i++ {
-			e := slice.Index(i)
-			// nolint
-			if err := decodePrimitive(&e, values[i]); err != nil {
-				toReduce++
+	slice := reflect.MakeSlice(f.Type(), 0, len(values))
+	for i, value := range values {
+		e := reflect.New(f.Type().Elem()).Elem()
+		if err := p.decodeElement(&e, value, a); err != nil {
+			if p.Strict {
+				return fmt.Errorf("element #%d `%s`: %v", i, value, err)
 			}
+			p.reject(a, value, err)
+			continue
 		}
+		slice = reflect.Append(slice, e)
 	}
-	if toReduce > 0 {
-		slice = slice.Slice(0, length-toReduce)
+	f.Set(slice)
+	return nil
+}
+
+// reject reports a discarded slice element or map entry to the Rejected
+// callback, when configured.
+func (p *Parser) reject(a *annotation, raw string, err error) {
+	if p.Rejected != nil {
+		p.Rejected(a.name, raw, err)
+	}
+}
+
+// decodePositions decodes the names of the toggles present in flagVal into a
+// slice of booleans, where the `positions` option maps every name to its
+// index (e.g. `positions=a;b;c` decodes `a;c` into [true false true]).
+func decodePositions(f *reflect.Value, flagVal string, a *annotation) error {
+	if f.Type().Elem().Kind() != reflect.Bool {
+		return fmt.Errorf("option `positions` requires a slice of booleans")
+	}
+	slice := reflect.MakeSlice(f.Type(), len(a.positions), len(a.positions))
+	for _, name := range strings.Split(flagVal, a.sep) {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		i := indexOf(a.positions, name)
+		if i < 0 {
+			return fmt.Errorf("unknown position `%s`, instead use %+v", name, a.positions)
+		}
+		slice.Index(i).SetBool(true)
 	}
 	f.Set(slice)
+	return nil
 }
 
-func decodePrimitive(f *reflect.Value, flagVal string) error {
+// decodeElement decodes a single slice element, which may be a slice or a map
+// itself, split by the inner separator.
+func (p *Parser) decodeElement(e *reflect.Value, value string, a *annotation) error {
+	if p.registered(e.Type()) {
+		return p.types[e.Type()](*e, value)
+	}
+	if (e.Kind() != reflect.Slice && e.Kind() != reflect.Map) || e.Type() == ipType {
+		return decodeValue(e, value, a)
+	}
+	inner := *a
+	inner.sep, inner.occurrences = a.innerSep, nil
+	if e.Kind() == reflect.Map {
+		return p.decodeMap(e, value, &inner)
+	}
+	return p.decodeSlice(e, value, &inner)
+}
+
+// sliceElements returns the raw elements of a slice of type t. When the flag
+// is repeated in the arguments, every occurrence contributes its own
+// elements, where occurrences of pairs hold a single element each.
+func sliceElements(t reflect.Type, flagVal string, a *annotation) []string {
+	if len(a.occurrences) < 2 {
+		return splitQuoted(flagVal, a.sep)
+	}
+	var elements []string
+	for _, o := range a.occurrences {
+		if isPair(t.Elem()) {
+			elements = append(elements, o)
+			continue
+		}
+		elements = append(elements, splitQuoted(o, a.sep)...)
+	}
+	return elements
+}
+
+// decodeMap decodes entries separated by semicolon (`;`), or the `sep`
+// option, with the key and
+// the value separated by colon (`:`). Keys and values are decoded against
+// the map's key and element types, malformed entries are discarded unless
+// the parser is strict.
+// Maps of empty structs are decoded as sets, where every entry is a key, and
+// entries of maps of booleans without a value are decoded as true.
+func (p *Parser) decodeMap(f *reflect.Value, flagVal string, a *annotation) error {
+	t := f.Type()
+	m := reflect.MakeMap(t)
+	set := t.Elem().Kind() == reflect.Struct && t.Elem().NumField() == 0
+	for _, x := range mapEntries(flagVal, a) {
+		if x = strings.TrimSpace(x); x == "" {
+			continue
+		}
+		k := reflect.New(t.Key()).Elem()
+		e := reflect.New(t.Elem()).Elem()
+		if err := decodeEntry(&k, &e, x, set, a); err != nil {
+			if p.Strict {
+				return err
+			}
+			p.reject(a, x, err)
+			continue
+		}
+		m.SetMapIndex(k, e)
+	}
+	f.Set(m)
+	return nil
+}
+
+// mapEntries returns the raw entries of a map. When the flag is repeated in
+// the arguments, every occurrence contributes its own entries, where later
+// occurrences override the keys of the earlier ones.
+func mapEntries(flagVal string, a *annotation) []string {
+	if len(a.occurrences) < 2 {
+		return strings.Split(flagVal, a.sep)
+	}
+	var entries []string
+	for _, o := range a.occurrences {
+		entries = append(entries, strings.Split(o, a.sep)...)
+	}
+	return entries
+}
+
+// decodeEntry decodes a single map entry into k and e, for sets the whole
+// entry is the key, while boolean values default to true when omitted.
+// Values of empty interfaces are inferred like the ones handed to a Setter.
+func decodeEntry(k, e *reflect.Value, entry string, set bool, a *annotation) error {
+	if set {
+		if err := decodeValue(k, entry, a); err != nil {
+			return fmt.Errorf("key `%s`: %v", entry, err)
+		}
+		return nil
+	}
+	kv := strings.SplitN(entry, ":", 2)
+	if len(kv) < 2 && e.Kind() == reflect.Bool {
+		kv = append(kv, "true")
+	}
+	if len(kv) < 2 {
+		return fmt.Errorf("malformed entry `%s`, expected `key:value`", entry)
+	}
+	key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+	if err := decodeValue(k, key, a); err != nil {
+		return fmt.Errorf("key `%s`: %v", key, err)
+	}
+	if e.Kind() == reflect.Interface && e.NumMethod() == 0 {
+		e.Set(reflect.ValueOf(inferValue(value)))
+		return nil
+	}
+	if err := decodeValue(e, value, a); err != nil {
+		return fmt.Errorf("value `%s` of key `%s`: %v", value, key, err)
+	}
+	return nil
+}
+
+// inferValue returns the typed representation of flagVal, trying bool,
+// int64 and float64 before falling back to the string itself.
+func inferValue(flagVal string) interface{} {
+	if strings.EqualFold(flagVal, "true") || strings.EqualFold(flagVal, "false") {
+		return strings.EqualFold(flagVal, "true")
+	}
+	if v, err := strconv.ParseInt(flagVal, 10, 64); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseFloat(flagVal, 64); err == nil {
+		return v
+	}
+	return flagVal
+}
+
+// isCustom reports whether v implements any of the interfaces used to
+// decode custom types.
+func isCustom(v reflect.Value) bool {
+	switch v.Interface().(type) {
+	case Decoder, ContextDecoder, Setter:
+		return true
+	}
+	return false
+}
+
+// isPair reports whether t is a struct of exactly two string fields, like
+// struct{ Name, Value string }, decoded from `name:value` strings.
+func isPair(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t.NumField() != 2 {
+		return false
+	}
+	for i := 0; i < 2; i++ {
+		if ft := t.Field(i); ft.PkgPath != "" || ft.Type.Kind() != reflect.String {
+			return false
+		}
+	}
+	return true
+}
+
+// decodePair decodes a `name:value` string into a pair, splitting it on the
+// first colon only.
+func decodePair(f *reflect.Value, flagVal string) error {
+	kv := strings.SplitN(flagVal, ":", 2)
+	if len(kv) < 2 {
+		return fmt.Errorf("malformed pair `%s`, expected `name:value`", flagVal)
+	}
+	f.Field(0).SetString(strings.TrimSpace(kv[0]))
+	f.Field(1).SetString(strings.TrimSpace(kv[1]))
+	return nil
+}
+
+// isZero reports whether f holds the zero value of its type.
+func isZero(f reflect.Value) bool {
+	return reflect.DeepEqual(f.Interface(), reflect.Zero(f.Type()).Interface())
+}
+
+// isValueStruct reports whether t is a struct decoded from a single value,
+// instead of being recursed into.
+func isValueStruct(t reflect.Type) bool {
+	_, versioned := versionedTypes[t]
+	return t == timeType || t == fileType || t == ratType || t == syncMapType || t == templateType || versioned || isSQLNull(t)
+}
+
+// decodeValue decodes flagVal into f, routing custom decoders and the types
+// which need the annotation options (like time.Time layouts) before the
+// primitive ones. Nil pointers to custom decoders are allocated.
+func decodeValue(f *reflect.Value, flagVal string, a *annotation) error {
+	if f.Kind() == reflect.Ptr && f.Type().Implements(decoderType) {
+		v := reflect.New(f.Type().Elem())
+		if err := v.Interface().(Decoder).Decode(flagVal); err != nil {
+			return err
+		}
+		f.Set(v)
+		return nil
+	}
+	if f.CanAddr() {
+		if decoder, ok := f.Addr().Interface().(Decoder); ok {
+			return decoder.Decode(flagVal)
+		}
+	}
+	if a.stringer > 0 && f.Kind() != reflect.Slice {
+		return decodeStringer(f, flagVal, a.stringer)
+	}
+	if ok, err := decodeKnown(f, flagVal); ok {
+		return err
+	}
+	if f.Type() == reflect.PtrTo(fileType) {
+		return decodeFile(f, flagVal, a)
+	}
+	if isSQLNull(f.Type()) {
+		return decodeSQLNull(f, flagVal, a)
+	}
+	if isPair(f.Type()) {
+		return decodePair(f, flagVal)
+	}
+	if a.boolean && f.Kind() == reflect.Ptr {
+		return decodeTristate(f, flagVal, a)
+	}
+	if f.Type() == timeType {
+		v, err := parseTime(flagVal, a.layout)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(v))
+		return nil
+	}
+	if ok, err := decodeNumber(f, flagVal, a); ok {
+		return err
+	}
+	return decodePrimitive(f, flagVal, a)
+}
+
+// parseTime parses flagVal with every layout of the `|` separated list, in
+// order, returning the first successful result.
+func parseTime(flagVal, layout string) (time.Time, error) {
+	layouts := strings.Split(layout, "|")
+	if len(layouts) == 1 {
+		return time.Parse(layout, flagVal)
+	}
+	for _, l := range layouts {
+		if v, err := time.Parse(l, flagVal); err == nil {
+			return v, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("value does not match any of the layouts %q", layouts)
+}
+
+// decodeTristate decodes flagVal into a pointer to bool, allocating it, so
+// absent flags are told apart from false ones by leaving the pointer nil.
+func decodeTristate(f *reflect.Value, flagVal string, a *annotation) error {
+	v, err := a.parseBool(flagVal)
+	if err != nil {
+		return err
+	}
+	b := reflect.New(f.Type().Elem())
+	b.Elem().SetBool(v)
+	f.Set(b)
+	return nil
+}
+
+// decodePrimitive decodes flagVal according to the kind of f, so named types
+// (e.g. `type Env string`) are decoded like their underlying type.
+func decodePrimitive(f *reflect.Value, flagVal string, a *annotation) error {
 	switch f.Kind() {
 	case reflect.Bool:
-		v, err := strconv.ParseBool(flagVal)
+		v, err := a.parseBool(flagVal)
 		if err != nil {
 			return err
 		}
@@ -230,7 +1662,9 @@ func decodePrimitive(f *reflect.Value, flagVal string) error {
 	case reflect.String:
 		f.SetString(flagVal)
 	case reflect.Interface:
-		f.Set(reflect.ValueOf(flagVal))
+		if v := reflect.ValueOf(flagVal); v.Type().AssignableTo(f.Type()) {
+			f.Set(v)
+		}
 	}
 	return nil
 }
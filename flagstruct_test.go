@@ -13,6 +13,7 @@ func TestLookup(t *testing.T) {
 		args     []string
 		expected string
 		arg      string
+		short    string
 	}
 
 	tests := []*test{
@@ -46,15 +47,110 @@ func TestLookup(t *testing.T) {
 			arg:      "-host",
 			expected: "127.0.0.1",
 		},
+		{
+			args:     []string{"-db-host=127.0.0.1"},
+			arg:      "host",
+			expected: "",
+		},
+		{
+			args:     []string{"-query=a=1&b=2"},
+			arg:      "query",
+			expected: "a=1&b=2",
+		},
+		{
+			args:     []string{"-v=true"},
+			arg:      "verbose",
+			short:    "v",
+			expected: "true",
+		},
+		{
+			args:     []string{"--v=true"},
+			arg:      "verbose",
+			short:    "v",
+			expected: "",
+		},
+		{
+			args:     []string{"--verbose=true"},
+			arg:      "verbose",
+			short:    "v",
+			expected: "true",
+		},
+		{
+			args:     []string{"--v=true"},
+			arg:      "v",
+			expected: "true",
+		},
 	}
 
-	for _, ts := range tests {
-		if result := lookup(ts.args, ts.arg); result != ts.expected {
-			t.Errorf("wrong result expected %s got %s", ts.expected, result)
+	for i, ts := range tests {
+		if result := lookup(ts.args, ts.arg, ts.short); result != ts.expected {
+			t.Errorf("case #%d: wrong result expected %s got %s", i, ts.expected, result)
 		}
 	}
 }
 
+func TestShortAlias(t *testing.T) {
+	type test struct {
+		Verbose bool `flag:"verbose,short=v"`
+		V       bool `flag:"v"`
+	}
+
+	var ts test
+	if err := (&Parser{Args: []string{"-v=true"}}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if !ts.Verbose {
+		t.Error("expected single dash short alias to match")
+	}
+
+	ts = test{}
+	if err := (&Parser{Args: []string{"--v=true"}}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if ts.Verbose || !ts.V {
+		t.Errorf("expected double dash to only match the long name, got %+v", ts)
+	}
+
+	ts = test{}
+	if err := (&Parser{Args: []string{"--verbose=true"}}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if !ts.Verbose || ts.V {
+		t.Errorf("expected double dash to match the long name, got %+v", ts)
+	}
+}
+
+func TestFilterPrefix(t *testing.T) {
+	args := []string{"-a.host=a", "--b.host=b", "-host=c", "-a.=d", "a.port=1"}
+	expected := []string{"-host=a", "-=d"}
+	if result := filterPrefix(args, "a."); !reflect.DeepEqual(result, expected) {
+		t.Errorf("wrong result expected %v got %v", expected, result)
+	}
+}
+
+func TestParserPrefixFilter(t *testing.T) {
+	type test struct {
+		Host    string `flag:"host,default=localhost"`
+		Port    int    `flag:"port"`
+		Verbose bool   `flag:"verbose"`
+	}
+	args := []string{"-server.host=example.com", "-server.port=80", "--client.port=8080", "-port=1", "--client.verbose", "-verbose"}
+
+	var server, client test
+	if err := (&Parser{Args: args, PrefixFilter: "server."}).Decode(&server); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if err := (&Parser{Args: args, PrefixFilter: "client."}).Decode(&client); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if expected := (test{Host: "example.com", Port: 80}); server != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, server)
+	}
+	if expected := (test{Host: "localhost", Port: 8080, Verbose: true}); client != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, client)
+	}
+}
+
 func TestInSlice(t *testing.T) {
 	type test struct {
 		values   []string
@@ -121,12 +217,48 @@ func TestParse(t *testing.T) {
 	}
 
 	for i, ts := range tests {
-		if result, _ := parse(ts.args, ts.tag); result != ts.expected {
+		var result string
+		if a, err := parseAnnotation(ts.tag); err == nil {
+			result, _ = new(Parser).resolve(&state{args: ts.args}, a)
+		}
+		if result != ts.expected {
 			t.Errorf("%d. wrong result expected %s got %s", i, ts.expected, result)
 		}
 	}
 }
 
+func TestCheckBounds(t *testing.T) {
+	type test struct {
+		tag   string
+		value string
+		err   bool
+	}
+
+	tests := []*test{
+		{tag: "version,gte=b", value: "c"},
+		{tag: "version,gte=b", value: "b"},
+		{tag: "version,gte=b", value: "a", err: true},
+		{tag: "version,lte=b", value: "c", err: true},
+		{tag: "version,gte=b,lte=d", value: "c"},
+		{tag: "version,gte=1.2.0", value: "1.10.0", err: true},
+		{tag: "version,gte=1.2.0,semver", value: "1.10.0"},
+		{tag: "version,gte=1.2.0,semver", value: "v1.1.9", err: true},
+		{tag: "version,lte=2.0.0,semver", value: "2.0.0-rc.1"},
+		{tag: "version,lte=2.0.0,semver", value: "2.0.1", err: true},
+		{tag: "version,lte=2.0.0,semver", value: "latest", err: true},
+	}
+
+	for i, ts := range tests {
+		a, err := parseAnnotation(ts.tag)
+		if err != nil {
+			t.Fatalf("case #%d: unexpected error %v", i, err)
+		}
+		if err := checkBounds(a, ts.value); (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+	}
+}
+
 func TestDecodeSlice(t *testing.T) {
 	type test struct {
 		value    string
@@ -145,17 +277,423 @@ func TestDecodeSlice(t *testing.T) {
 		{value: "1;", expected: []int{1}},
 		{value: "1;2", expected: []int{1, 2}},
 		{value: "1;;3", expected: []int{1, 3}},
+		{value: "a;2;b;4", expected: []int{2, 4}},
 	}
 	var s Struct
 	f := reflect.ValueOf(&s).Elem().Field(0)
 	for i, ts := range tests {
-		decodeSlice(&f, ts.value)
+		_ = new(Parser).decodeSlice(&f, ts.value, newAnnotation(""))
 		if !reflect.DeepEqual(ts.expected, s.Slice) {
 			t.Errorf("%d. wrong slice expected %v got %v", i, ts.expected, s.Slice)
 		}
 	}
 }
 
+type color struct {
+	name string
+}
+
+func (c *color) Decode(repl string) error {
+	if repl != "red" && repl != "blue" {
+		return fmt.Errorf("unknown color %s", repl)
+	}
+	c.name = repl
+	return nil
+}
+
+func TestDecodeMatrix(t *testing.T) {
+	type test struct {
+		Matrix [][]string `flag:"matrix,sep=|"`
+		Ints   [][]int    `flag:"ints,innersep=/"`
+	}
+
+	type decode struct {
+		args     []string
+		expected test
+	}
+	tests := []*decode{
+		{
+			args:     []string{"-matrix=a,b|c,d", "-ints=1/2;3/4"},
+			expected: test{Matrix: [][]string{{"a", "b"}, {"c", "d"}}, Ints: [][]int{{1, 2}, {3, 4}}},
+		},
+		{
+			args:     []string{"-matrix=a,b,c|d||e,", "-ints=1;2/x/3"},
+			expected: test{Matrix: [][]string{{"a", "b", "c"}, {"d"}, {"e"}}, Ints: [][]int{{1}, {2, 3}}},
+		},
+	}
+
+	for i, ts := range tests {
+		var result test
+		if err := (&Parser{Args: ts.args}).Decode(&result); err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if !reflect.DeepEqual(result, ts.expected) {
+			t.Errorf("case #%d: wrong assignment expected %v got %v", i, ts.expected, result)
+		}
+	}
+}
+
+func TestDecodePositions(t *testing.T) {
+	type test struct {
+		Features []bool `flag:"features,positions=auth;cache;metrics"`
+		Invalid  []int  `flag:"invalid,positions=a;b"`
+	}
+
+	type decode struct {
+		args     []string
+		expected []bool
+		err      bool
+	}
+	tests := []*decode{
+		{args: []string{"-features=auth;metrics"}, expected: []bool{true, false, true}},
+		{args: []string{"-features=cache"}, expected: []bool{false, true, false}},
+		{args: []string{"-features=cache;;cache"}, expected: []bool{false, true, false}},
+		{args: []string{"-features=auth;queue"}, err: true},
+		{args: []string{"-invalid=a"}, err: true},
+	}
+
+	for i, ts := range tests {
+		var result test
+		err := (&Parser{Args: ts.args}).Decode(&result)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+		if !ts.err && !reflect.DeepEqual(result.Features, ts.expected) {
+			t.Errorf("case #%d: wrong assignment expected %v got %v", i, ts.expected, result.Features)
+		}
+	}
+}
+
+func TestDecodeSliceStrict(t *testing.T) {
+	type fields struct {
+		Colors []*color
+		Values []color
+		Ints   []int
+	}
+
+	type test struct {
+		value    string
+		field    int
+		strict   bool
+		expected interface{}
+		err      string
+	}
+
+	tests := []*test{
+		{field: 0, value: "red;green;blue", expected: []*color{{"red"}, {"blue"}}},
+		{field: 0, value: "red;green;blue", strict: true, err: "element #1 `green`: unknown color green"},
+		{field: 0, value: "red;blue", strict: true, expected: []*color{{"red"}, {"blue"}}},
+		{field: 1, value: "blue;green", expected: []color{{"blue"}}},
+		{field: 1, value: "blue;green", strict: true, err: "element #1 `green`: unknown color green"},
+		{field: 2, value: "1;a", strict: true, err: "element #1 `a`: strconv.ParseInt: parsing \"a\": invalid syntax"},
+	}
+
+	for i, ts := range tests {
+		var s fields
+		f := reflect.ValueOf(&s).Elem().Field(ts.field)
+		err := (&Parser{Strict: ts.strict}).decodeSlice(&f, ts.value, newAnnotation(""))
+		if ts.err != "" {
+			if err == nil || err.Error() != ts.err {
+				t.Errorf("case #%d: wrong error expected %s got %v", i, ts.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if !reflect.DeepEqual(ts.expected, f.Interface()) {
+			t.Errorf("case #%d: wrong slice expected %v got %v", i, ts.expected, f)
+		}
+	}
+}
+
+func TestRejected(t *testing.T) {
+	type test struct {
+		Ports   []int          `flag:"ports"`
+		Weights map[string]int `flag:"weights"`
+		Matrix  [][]int        `flag:"matrix"`
+	}
+
+	type rejection struct {
+		name, raw string
+	}
+	var rejected []rejection
+	p := Parser{
+		Args: []string{"-ports=80;http;443;-", "-weights=a:1;b;c:x", "-matrix=1,a;2"},
+		Rejected: func(name, raw string, err error) {
+			if err == nil {
+				t.Errorf("expected the error of the rejected input %s", raw)
+			}
+			rejected = append(rejected, rejection{name, raw})
+		},
+	}
+
+	var ts test
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	expected := []rejection{
+		{"ports", "http"}, {"ports", "-"},
+		{"weights", "b"}, {"weights", "c:x"},
+		{"matrix", "a"},
+	}
+	if !reflect.DeepEqual(rejected, expected) {
+		t.Errorf("wrong rejected inputs expected %v got %v", expected, rejected)
+	}
+	if len(ts.Ports) != 2 || len(ts.Weights) != 1 || !reflect.DeepEqual(ts.Matrix, [][]int{{1}, {2}}) {
+		t.Errorf("wrong assignment got %+v", ts)
+	}
+}
+
+func TestDecodeMapStrict(t *testing.T) {
+	type test struct {
+		value string
+		err   string
+	}
+
+	tests := []*test{
+		{value: "1:a;2:b"},
+		{value: "1:a;b", err: "malformed entry `b`, expected `key:value`"},
+		{value: "1:a;x:b", err: "key `x`: strconv.ParseInt: parsing \"x\": invalid syntax"},
+	}
+
+	for i, ts := range tests {
+		var m map[int]string
+		f := reflect.ValueOf(&m).Elem()
+		err := (&Parser{Strict: true}).decodeMap(&f, ts.value, newAnnotation(""))
+		if (ts.err == "" && err != nil) || (ts.err != "" && (err == nil || err.Error() != ts.err)) {
+			t.Errorf("case #%d: wrong error expected %s got %v", i, ts.err, err)
+		}
+	}
+}
+
+func TestLookupAll(t *testing.T) {
+	args := []string{"-tag=a", "--tag=b", "-t=c", "--t=d", "-tags=e", "-tag=f"}
+	expected := []string{"a", "b", "c", "f"}
+	if result := lookupAll(args, "tag", "t"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("wrong result expected %v got %v", expected, result)
+	}
+	if result := lookupAll(args, "none", ""); result != nil {
+		t.Errorf("wrong result expected nil got %v", result)
+	}
+}
+
+func TestDecodeRepeated(t *testing.T) {
+	type header struct {
+		Name, Value string
+	}
+	type test struct {
+		Headers []header `flag:"header"`
+		Tags    []string `flag:"tag"`
+		Single  []header `flag:"single,default=Accept:*/*;Host:a"`
+	}
+	args := []string{
+		"-header=Accept: text/html",
+		"-tag=a;b",
+		"-header=Cookie: a=1; b=2",
+		"-tag=c",
+		"-header=Accept: application/json",
+		"-header=",
+		"-header=invalid",
+	}
+
+	var ts test
+	if err := (&Parser{Args: args}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{
+		Headers: []header{
+			{Name: "Accept", Value: "text/html"},
+			{Name: "Cookie", Value: "a=1; b=2"},
+			{Name: "Accept", Value: "application/json"},
+		},
+		Tags:   []string{"a", "b", "c"},
+		Single: []header{{Name: "Accept", Value: "*/*"}, {Name: "Host", Value: "a"}},
+	}
+	if !reflect.DeepEqual(ts, expected) {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+	if err := (&Parser{Args: args, Strict: true}).Decode(&ts); err == nil {
+		t.Error("expected error for a malformed pair on strict mode")
+	}
+}
+
+func TestOccurrenceCount(t *testing.T) {
+	type test struct {
+		Peers []string `flag:"peer,mincount=1,maxcount=3"`
+		Tags  []string `flag:"tag,maxcount=1"`
+	}
+
+	type decode struct {
+		args []string
+		err  string
+	}
+	tests := []*decode{
+		{args: []string{"-peer=a"}},
+		{args: []string{"-peer=a", "-peer=b", "--peer=c", "-tag=a;b"}},
+		{args: []string{}, err: "flagstruct: flag 'peer' must be provided at least once, got 0"},
+		{args: []string{"-tag=a"}, err: "flagstruct: flag 'peer' must be provided at least once, got 0"},
+		{
+			args: []string{"-peer=a", "-peer=b", "-peer=c", "-peer=d"},
+			err:  "flagstruct: flag 'peer' must be provided at most 3 times, got 4",
+		},
+		{args: []string{"-peer=a", "-tag=a", "-tag=b"}, err: "flagstruct: flag 'tag' must be provided at most once, got 2"},
+	}
+
+	for i, ts := range tests {
+		var result test
+		err := (&Parser{Args: ts.args}).Decode(&result)
+		if (ts.err == "" && err != nil) || (ts.err != "" && (err == nil || err.Error() != ts.err)) {
+			t.Errorf("case #%d: wrong error expected %s got %v", i, ts.err, err)
+		}
+	}
+
+	var env struct {
+		Peers []string `flag:"peer,mincount=1,env=PEERS"`
+		Nodes []string `flag:"node,mincount=1,default=a;b"`
+	}
+	os.Setenv("PEERS", "a;b")
+	defer os.Unsetenv("PEERS")
+	if err := (&Parser{Args: []string{}, Precedence: []Origin{OriginArgs, OriginEnv}}).Decode(&env); err != nil {
+		t.Errorf("expected values from other sources to count as an occurrence, got %v", err)
+	}
+
+	var invalid struct {
+		Peers []string `flag:"peer,mincount=a"`
+	}
+	if err := (&Parser{Args: []string{}}).Decode(&invalid); err == nil || err.Error() != "flagstruct: malformed annotation, invalid mincount `a`" {
+		t.Errorf("wrong error for a malformed mincount got %v", err)
+	}
+}
+
+func TestDuplicateScalarIsError(t *testing.T) {
+	type test struct {
+		Port   int            `flag:"port,short=p"`
+		Tags   []string       `flag:"tag"`
+		Labels map[string]int `flag:"label"`
+		Pair   [2]int         `flag:"pair"`
+	}
+
+	var ts test
+	p := Parser{Args: []string{"-port=80", "-tag=a", "-tag=b", "-label=a:1", "-label=b:2", "-pair=1", "-pair=2"}, DuplicateScalarIsError: true}
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if expected := (test{Port: 80, Tags: []string{"a", "b"}, Labels: map[string]int{"a": 1, "b": 2}, Pair: [2]int{1, 2}}); !reflect.DeepEqual(ts, expected) {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	for _, args := range [][]string{{"-port=80", "-port=443"}, {"-port=80", "-p=443"}} {
+		p.Args = args
+		expected := "flagstruct: flag 'port' is provided 2 times, expected once"
+		if err := p.Decode(&ts); err == nil || err.Error() != expected {
+			t.Errorf("wrong error for %v expected %s got %v", args, expected, err)
+		}
+	}
+
+	p.DuplicateScalarIsError = false
+	if err := p.Decode(&ts); err != nil || ts.Port != 80 {
+		t.Errorf("expected the first occurrence to be used, got %d (%v)", ts.Port, err)
+	}
+}
+
+func TestDecodeMapDecoder(t *testing.T) {
+	type test struct {
+		Colors   map[string]color `flag:"colors"`
+		Pointers map[int]*color   `flag:"pointers"`
+		Strict   map[string]color `flag:"strict"`
+	}
+
+	var ts test
+	args := []string{"-colors=sky:blue;grass:green;rose:red", "-pointers=1:red;2:blue"}
+	if err := (&Parser{Args: args}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if expected := map[string]color{"sky": {"blue"}, "rose": {"red"}}; !reflect.DeepEqual(ts.Colors, expected) {
+		t.Errorf("wrong map assignment expected %v got %v", expected, ts.Colors)
+	}
+	if expected := map[int]*color{1: {"red"}, 2: {"blue"}}; !reflect.DeepEqual(ts.Pointers, expected) {
+		t.Errorf("wrong map assignment expected %v got %v", expected, ts.Pointers)
+	}
+	if err := (&Parser{Args: []string{"-strict=grass:green"}, Strict: true}).Decode(&ts); err == nil {
+		t.Error("expected error from the custom decoder on strict mode")
+	}
+}
+
+func TestDecodeSliceDefault(t *testing.T) {
+	type fields struct {
+		Ports []int    `flag:"ports,default=80;443"`
+		Hosts []string `flag:"hosts"`
+	}
+
+	var s fields
+	os.Args = []string{"./example"}
+	if err := Decode(&s); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if !reflect.DeepEqual(s.Ports, []int{80, 443}) {
+		t.Errorf("wrong default slice expected [80 443] got %v", s.Ports)
+	}
+	if s.Hosts != nil {
+		t.Errorf("wrong slice expected nil for an absent flag got %v", s.Hosts)
+	}
+
+	os.Args = []string{"./example", "-ports=8080"}
+	if err := Decode(&s); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if !reflect.DeepEqual(s.Ports, []int{8080}) {
+		t.Errorf("wrong slice expected [8080] got %v", s.Ports)
+	}
+}
+
+func TestDecodeMap(t *testing.T) {
+	type fields struct {
+		Strings map[string]string
+		Ints    map[int]string
+		Bools   map[bool]int
+		Set     map[string]struct{}
+		IntSet  map[int]struct{}
+		Flags   map[string]bool
+		Params  map[string]interface{}
+	}
+
+	type test struct {
+		value    string
+		field    int
+		expected interface{}
+	}
+
+	tests := []*test{
+		{field: 0, value: "", expected: map[string]string{}},
+		{field: 0, value: "a:1;b:2", expected: map[string]string{"a": "1", "b": "2"}},
+		{field: 0, value: "a;b:2:3", expected: map[string]string{"b": "2:3"}},
+		{field: 1, value: "1:a;2:b", expected: map[int]string{1: "a", 2: "b"}},
+		{field: 1, value: "1:a;x:b;:c", expected: map[int]string{1: "a"}},
+		{field: 2, value: "true:1;false:0", expected: map[bool]int{true: 1, false: 0}},
+		{field: 2, value: "yes:1;false:a;true:2", expected: map[bool]int{true: 2}},
+		{field: 3, value: "a;b;;a;c", expected: map[string]struct{}{"a": {}, "b": {}, "c": {}}},
+		{field: 3, value: "a:1", expected: map[string]struct{}{"a:1": {}}},
+		{field: 4, value: "1;x;2;1", expected: map[int]struct{}{1: {}, 2: {}}},
+		{field: 5, value: "a;b:false;c", expected: map[string]bool{"a": true, "b": false, "c": true}},
+		{field: 5, value: "a:true;b:no;c", expected: map[string]bool{"a": true, "c": true}},
+		{
+			field:    6,
+			value:    "a:1;b:true;c:hello;d:1.5;e:",
+			expected: map[string]interface{}{"a": int64(1), "b": true, "c": "hello", "d": 1.5, "e": ""},
+		},
+	}
+
+	var s fields
+	for i, ts := range tests {
+		f := reflect.ValueOf(&s).Elem().Field(ts.field)
+		_ = new(Parser).decodeMap(&f, ts.value, newAnnotation(""))
+		if !reflect.DeepEqual(ts.expected, f.Interface()) {
+			t.Errorf("case #%d: wrong map expected %v got %v", i, ts.expected, f)
+		}
+	}
+}
+
 func TestDecodePrimitive(t *testing.T) {
 	type fields struct {
 		Bool      bool
@@ -195,22 +733,278 @@ func TestDecodePrimitive(t *testing.T) {
 	var s fields
 	for i, ts := range tests {
 		f := reflect.ValueOf(&s).Elem().Field(ts.field)
-		_ = decodePrimitive(&f, ts.value)
+		_ = decodePrimitive(&f, ts.value, newAnnotation(""))
 		if ts.expected != fmt.Sprintf("%v", f) {
 			t.Errorf("case #%d: expected %v got %v", i, ts.expected, f)
 		}
 	}
 }
 
+func TestDecodeTristate(t *testing.T) {
+	type test struct {
+		Feature *bool  `flag:"feature,short=f"`
+		Cache   *bool  `flag:"cache,default=true"`
+		Verbose bool   `flag:"verbose"`
+		Name    string `flag:"name"`
+	}
+
+	yes, no := true, false
+	type decode struct {
+		args    []string
+		feature *bool
+		cache   bool
+		verbose bool
+		err     bool
+	}
+	tests := []*decode{
+		{args: []string{}, cache: true},
+		{args: []string{"-feature"}, feature: &yes, cache: true},
+		{args: []string{"--feature"}, feature: &yes, cache: true},
+		{args: []string{"-f"}, feature: &yes, cache: true},
+		{args: []string{"-feature=false"}, feature: &no, cache: true},
+		{args: []string{"-feature=true", "-cache=false", "-verbose"}, feature: &yes, verbose: true},
+		{args: []string{"-feature=maybe"}, err: true},
+	}
+
+	for i, ts := range tests {
+		var result test
+		err := (&Parser{Args: append(ts.args, "-name")}).Decode(&result)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+		if ts.err {
+			continue
+		}
+		if (ts.feature == nil) != (result.Feature == nil) || (ts.feature != nil && *ts.feature != *result.Feature) {
+			t.Errorf("case #%d: wrong feature expected %v got %v", i, ts.feature, result.Feature)
+		}
+		if result.Cache == nil || *result.Cache != ts.cache || result.Verbose != ts.verbose || result.Name != "" {
+			t.Errorf("case #%d: wrong assignment got %+v", i, result)
+		}
+	}
+}
+
+type (
+	namedEnv   string
+	namedLevel uint8
+	namedRatio float64
+	namedCount int
+)
+
+func TestDecodeNamedPrimitive(t *testing.T) {
+	type test struct {
+		Env    namedEnv                `flag:"env,allowed=dev;prod"`
+		Level  namedLevel              `flag:"level"`
+		Ratio  namedRatio              `flag:"ratio"`
+		Counts []namedCount            `flag:"counts"`
+		Envs   map[namedEnv]namedCount `flag:"envs"`
+	}
+
+	var ts test
+	p := Parser{Args: []string{"-env=prod", "-level=3", "-ratio=0.25", "-counts=1;2", "-envs=dev:1;prod:2"}}
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{
+		Env:    "prod",
+		Level:  3,
+		Ratio:  0.25,
+		Counts: []namedCount{1, 2},
+		Envs:   map[namedEnv]namedCount{"dev": 1, "prod": 2},
+	}
+	if !reflect.DeepEqual(ts, expected) {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	p.Args = []string{"-level=256"}
+	if err := p.Decode(&ts); err == nil {
+		t.Error("expected error for a value overflowing the underlying type")
+	}
+}
+
+func TestDecodeTime(t *testing.T) {
+	type fields struct {
+		Time  time.Time   `flag:"time"`
+		Date  time.Time   `flag:"date,layout=2006-01-02"`
+		Times []time.Time `flag:"times"`
+	}
+	first := time.Date(2020, time.March, 1, 10, 0, 0, 0, time.UTC)
+	second := time.Date(2020, time.March, 2, 10, 0, 0, 0, time.UTC)
+
+	var s fields
+	os.Args = []string{
+		"./example",
+		"-time=2020-03-01T10:00:00Z",
+		"-date=2020-03-02",
+		"-times=2020-03-01T10:00:00Z;2020-03-02;2020-03-02T10:00:00Z",
+	}
+	if err := Decode(&s); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if !s.Time.Equal(first) {
+		t.Errorf("wrong time expected %v got %v", first, s.Time)
+	}
+	if expected := time.Date(2020, time.March, 2, 0, 0, 0, 0, time.UTC); !s.Date.Equal(expected) {
+		t.Errorf("wrong date expected %v got %v", expected, s.Date)
+	}
+	if len(s.Times) != 2 || !s.Times[0].Equal(first) || !s.Times[1].Equal(second) {
+		t.Errorf("wrong times expected [%v %v] got %v", first, second, s.Times)
+	}
+
+	os.Args = []string{"./example", "-time=2020-03-01"}
+	if err := Decode(&s); err == nil {
+		t.Error("expected error for a value not matching the layout")
+	}
+
+	var multi struct {
+		Date time.Time `flag:"date,layout=2006-01-02|02/01/2006|Jan 2 2006"`
+	}
+	p := Parser{Args: []string{"-date=02/03/2020"}}
+	if err := p.Decode(&multi); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if expected := time.Date(2020, time.March, 2, 0, 0, 0, 0, time.UTC); !multi.Date.Equal(expected) {
+		t.Errorf("wrong date expected %v got %v", expected, multi.Date)
+	}
+	p.Args = []string{"-date=2020"}
+	expected := "flagstruct: could not decode value `2020` to kind `struct`: " +
+		`value does not match any of the layouts ["2006-01-02" "02/01/2006" "Jan 2 2006"]`
+	if err := p.Decode(&multi); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected %s got %v", expected, err)
+	}
+}
+
+type level int
+
+func (l *level) SetFromFlag(v interface{}) error {
+	switch v := v.(type) {
+	case int64:
+		*l = level(v)
+	case string:
+		if v != "debug" {
+			return fmt.Errorf("unknown level %s", v)
+		}
+		*l = -1
+	default:
+		return fmt.Errorf("unexpected type %T", v)
+	}
+	return nil
+}
+
+func TestInferValue(t *testing.T) {
+	type test struct {
+		value    string
+		expected interface{}
+	}
+
+	tests := []*test{
+		{value: "true", expected: true},
+		{value: "FALSE", expected: false},
+		{value: "1", expected: int64(1)},
+		{value: "-10", expected: int64(-10)},
+		{value: "1.5", expected: 1.5},
+		{value: "hello", expected: "hello"},
+		{value: "", expected: ""},
+	}
+
+	for i, ts := range tests {
+		if result := inferValue(ts.value); result != ts.expected {
+			t.Errorf("case #%d: wrong result expected %#v got %#v", i, ts.expected, result)
+		}
+	}
+}
+
+func TestSetter(t *testing.T) {
+	type test struct {
+		Level level `flag:"level"`
+	}
+
+	var ts test
+	if err := (&Parser{Args: []string{"-level=3"}}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if ts.Level != 3 {
+		t.Errorf("wrong setter assignment expected 3 got %d", ts.Level)
+	}
+	if err := (&Parser{Args: []string{"-level=debug"}}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if ts.Level != -1 {
+		t.Errorf("wrong setter assignment expected -1 got %d", ts.Level)
+	}
+	if err := (&Parser{Args: []string{"-level=true"}}).Decode(&ts); err == nil {
+		t.Error("expected error from the setter for an unexpected type")
+	}
+}
+
+type Store interface {
+	Name() string
+}
+
+type fileStore struct {
+	Path string `flag:"store-path,default=/tmp"`
+}
+
+func (s *fileStore) Name() string { return "file" }
+
+type ipStore struct {
+	Addr string
+}
+
+func (s *ipStore) Name() string { return "ip" }
+
+func (s *ipStore) Decode(repl string) error {
+	s.Addr = repl
+	return nil
+}
+
+func TestDecodeInterface(t *testing.T) {
+	type test struct {
+		Store
+		Backup   Store `flag:"backup"`
+		Remote   Store `flag:"remote"`
+		Untagged fmt.Stringer
+		Any      interface{} `flag:"any"`
+	}
+	args := []string{"-store-path=/var", "-backup=b", "-remote=10.0.0.1", "-any=value"}
+
+	var ts test
+	if err := (&Parser{Args: args}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with nil interface fields: %v", err)
+	}
+	if ts.Store != nil || ts.Backup != nil || ts.Remote != nil {
+		t.Errorf("expected nil interface fields to be skipped, got %+v", ts)
+	}
+	if ts.Any != "value" {
+		t.Errorf("wrong assignment expected `value` got %v", ts.Any)
+	}
+
+	fs := &fileStore{}
+	ts = test{Store: fs, Backup: &fileStore{}, Remote: &ipStore{}}
+	if err := (&Parser{Args: args}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with populated interface fields: %v", err)
+	}
+	if fs.Path != "/var" {
+		t.Errorf("wrong embedded interface assignment expected `/var` got %s", fs.Path)
+	}
+	if p := ts.Backup.(*fileStore).Path; p != "/var" {
+		t.Errorf("wrong interface assignment expected `/var` got %s", p)
+	}
+	if addr := ts.Remote.(*ipStore).Addr; addr != "10.0.0.1" {
+		t.Errorf("wrong custom decoder assignment expected `10.0.0.1` got %s", addr)
+	}
+}
+
 func TestDecode(t *testing.T) {
 	os.Args = []string{"./example"}
 	type testDB struct {
-		Host     string        `flag:"db-host,default=127.0.0.1"`
-		Port     int           `flag:"db-port,default=5672"`
-		User     string        `flag:"db-user,required"`
-		Password string        `flag:"db-password"`
-		Timeout  time.Duration `flag:"db-timeout,default=5s"`
-		Sequence []int         `flag:"db-sequence"`
+		Host     string         `flag:"db-host,default=127.0.0.1"`
+		Port     int            `flag:"db-port,default=5672"`
+		User     string         `flag:"db-user,required"`
+		Password string         `flag:"db-password"`
+		Timeout  time.Duration  `flag:"db-timeout,default=5s"`
+		Sequence []int          `flag:"db-sequence"`
+		Weights  map[int]string `flag:"db-weights"`
 	}
 	type test struct {
 		// nolint
@@ -219,7 +1013,8 @@ func TestDecode(t *testing.T) {
 		TagWithoutValue int     `flag:"no-value"`
 		WrongValueType  float32 `flag:"wrong,default=a"`
 		Database        testDB
-		LimitedValue    int `flag:"limited,allowed=2;4;6,default=4"`
+		LimitedValue    int    `flag:"limited,allowed=2;4;6,default=4"`
+		APIVersion      string `flag:"api-version,gte=1.2.0,semver"`
 	}
 	var ts test
 	if err := Decode(nil); err == nil {
@@ -253,10 +1048,13 @@ func TestDecode(t *testing.T) {
 	if err := Decode(&ts); err == nil {
 		t.Error("expected an error for required field db-user")
 	}
-	os.Args = []string{"./example", "-wrong=1", "-db-sequence=1;2;3", "-db-user=root"}
+	os.Args = []string{"./example", "-wrong=1", "-db-sequence=1;2;3", "-db-user=root", "-db-weights=1:a;2:b"}
 	if err := Decode(&ts); err != nil {
 		t.Errorf("unexpected error with a valid case: %v", err)
 	}
+	if !reflect.DeepEqual(ts.Database.Weights, map[int]string{1: "a", 2: "b"}) {
+		t.Errorf("wrong map assignment, expected map[1:a 2:b] got %+v", ts.Database.Weights)
+	}
 	if fmt.Sprintf("%v", ts.Database.Timeout) != "5s" {
 		t.Errorf("wrong expected timeout")
 	}
@@ -274,4 +1072,396 @@ func TestDecode(t *testing.T) {
 	if ts.LimitedValue != 6 {
 		t.Errorf("wrong `allowed` assignment expected `6` got `%d`", ts.LimitedValue)
 	}
+	os.Args = []string{"./example", "-wrong=1", "-db-user=root", "-api-version=1.1.0"}
+	if err := Decode(&ts); err == nil {
+		t.Error("expected error for a version lower than the minimum")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type testDB struct {
+		Host string `flag:"db-host,default=127.0.0.1"`
+		User string `flag:"db-user,required"`
+	}
+	type test struct {
+		Timeout  time.Duration `flag:"timeout,default=5s"`
+		Database testDB
+		Replica  *testDB
+	}
+	ts := test{
+		Timeout:  time.Second,
+		Database: testDB{Host: "db", User: "admin"},
+		Replica:  &testDB{Host: "replica", User: "admin"},
+	}
+	expected := test{
+		Timeout:  ts.Timeout,
+		Database: ts.Database,
+		Replica:  &testDB{Host: "replica", User: "admin"},
+	}
+
+	if err := Validate(ts); err != ErrInvalidType {
+		t.Errorf("expected error for non pointer argument, got %v", err)
+	}
+	if err := Validate(new(string)); err != ErrInvalidType {
+		t.Errorf("expected error for non struct pointer argument, got %v", err)
+	}
+
+	os.Args = []string{"./example", "-timeout=1m"}
+	if err := Validate(&ts); err == nil {
+		t.Error("expected an error for required field db-user")
+	}
+	os.Args = []string{"./example", "-timeout=1m", "-db-user=root", "-db-host=localhost"}
+	if err := Validate(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if !reflect.DeepEqual(ts, expected) {
+		t.Errorf("expected target to be untouched, got %+v", ts)
+	}
+	if err := Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if ts.Replica.User != "root" || ts.Database.Host != "localhost" || ts.Timeout != time.Minute {
+		t.Errorf("expected target to be decoded after validation, got %+v", ts)
+	}
+}
+
+func TestParserJSONFallback(t *testing.T) {
+	type test struct {
+		Host    string `json:"host,omitempty"`
+		Port    int    `json:"port" flag:"server-port,default=8080"`
+		Secret  string `json:"-"`
+		Name    string
+		Verbose bool `flag:"verbose"`
+	}
+	args := []string{"-host=localhost", "-port=9090", "-Secret=foo", "-Name=bar", "-verbose=true"}
+
+	var ts test
+	if err := (&Parser{Args: args}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if ts.Host != "" {
+		t.Errorf("wrong assignment expected empty for json tagged field without fallback, got %s", ts.Host)
+	}
+
+	ts = test{}
+	if err := (&Parser{Args: args, JSONFallback: true}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{Host: "localhost", Port: 8080, Verbose: true}
+	if ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+}
+
+func TestDecodeWithDefaults(t *testing.T) {
+	type testDB struct {
+		Host string `flag:"db-host"`
+		Port int    `flag:"db-port,default=5432"`
+	}
+	type test struct {
+		Name     string        `flag:"name"`
+		Timeout  time.Duration `flag:"timeout,default=5s"`
+		Tags     []string      `flag:"tags"`
+		Database testDB
+		Replica  *testDB
+	}
+	defaults := test{
+		Name:     "default-name",
+		Timeout:  time.Minute,
+		Tags:     []string{"a"},
+		Database: testDB{Host: "db", Port: 1},
+		Replica:  &testDB{Host: "replica", Port: 2},
+	}
+
+	os.Args = []string{"./example", "-name=flag-name", "-db-port=3306"}
+	ts := test{Replica: &testDB{}}
+	if err := DecodeWithDefaults(&ts, defaults); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{
+		Name:     "flag-name",
+		Timeout:  5 * time.Second,
+		Tags:     []string{"a"},
+		Database: testDB{Host: "db", Port: 3306},
+		Replica:  &testDB{Host: "replica", Port: 3306},
+	}
+	if !reflect.DeepEqual(ts, expected) {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	ts = test{}
+	if err := DecodeWithDefaults(&ts, &defaults); err != nil {
+		t.Errorf("unexpected error with a pointer to defaults: %v", err)
+	}
+	if err := DecodeWithDefaults(&ts, testDB{}); err == nil {
+		t.Error("expected error for defaults of a different type")
+	}
+	if err := DecodeWithDefaults(ts, defaults); err != ErrInvalidType {
+		t.Errorf("expected error for non pointer argument, got %v", err)
+	}
+}
+
+func TestNonZero(t *testing.T) {
+	type test struct {
+		Port    int           `flag:"port,nonzero"`
+		Timeout time.Duration `flag:"timeout,nonzero,default=5s"`
+		Host    string        `flag:"host,nonzero,required"`
+	}
+
+	type decode struct {
+		args []string
+		err  bool
+	}
+	tests := []*decode{
+		{args: []string{"-port=80", "-host=a"}},
+		{args: []string{"-port=0", "-host=a"}, err: true},
+		{args: []string{"-host=a"}, err: true},
+		{args: []string{"-port=80", "-host=a", "-timeout=0s"}, err: true},
+	}
+
+	for i, ts := range tests {
+		var result test
+		err := (&Parser{Args: ts.args}).Decode(&result)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+		if err == nil && result.Timeout != 5*time.Second {
+			t.Errorf("case #%d: wrong default expected 5s got %v", i, result.Timeout)
+		}
+	}
+}
+
+func TestDecodeAll(t *testing.T) {
+	type server struct {
+		Host  string `flag:"host,default=localhost"`
+		Debug bool   `flag:"debug"`
+	}
+	type client struct {
+		Host    string        `flag:"host"`
+		Timeout time.Duration `flag:"timeout,required"`
+	}
+	args := []string{"-host=example.com", "-debug=true", "-timeout=1s"}
+
+	var s server
+	var c client
+	if err := DecodeAll(args, &s, &c); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if s.Host != "example.com" || !s.Debug || c.Host != "example.com" || c.Timeout != time.Second {
+		t.Errorf("wrong assignment got %+v and %+v", s, c)
+	}
+
+	err := DecodeAll([]string{"-debug=a"}, &s, &c, s)
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 3 {
+		t.Errorf("expected an error for every target got %v", err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	type test struct {
+		User     string `flag:"user"`
+		Password string `flag:"password,redact"`
+		Pin      int    `flag:"pin,redact,default=1234"`
+		Token    string `flag:"token,redact"`
+	}
+
+	consumed := make(map[string]interface{})
+	p := Parser{
+		Args: []string{"-user=root", "-password=secret"},
+		Redact: func(name string, value interface{}) {
+			consumed[name] = value
+		},
+	}
+	var ts test
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if expected := (test{User: "root"}); ts != expected {
+		t.Errorf("expected redacted fields to be zeroed, got %+v", ts)
+	}
+	expected := map[string]interface{}{"password": "secret", "pin": 1234}
+	if !reflect.DeepEqual(consumed, expected) {
+		t.Errorf("wrong consumed values expected %v got %v", expected, consumed)
+	}
+
+	if err := (&Parser{Args: []string{}}).Decode(&ts); err == nil {
+		t.Error("expected error for redacted fields without callback")
+	}
+
+	var calls []interface{}
+	p = Parser{
+		Args: []string{"-password=secret"},
+		Redact: func(name string, value interface{}) {
+			calls = append(calls, value)
+		},
+	}
+	if err := p.Validate(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("expected no calls while validating, got %v", calls)
+	}
+
+	type secret struct {
+		Password string `flag:"password,redact"`
+		Level    string `flag:"level,allowed=debug;info"`
+	}
+	ch := make(chan []string, 2)
+	ch <- []string{"-password=s1", "-level=trace"}
+	ch <- []string{"-password=s2"}
+	close(ch)
+	p.DecodeStream(&secret{}, ch, nil)
+	if expected := []interface{}{"s2"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("wrong calls expected %v got %v", expected, calls)
+	}
+}
+
+func TestNestedPrefix(t *testing.T) {
+	type conn struct {
+		Host string `flag:"host,default=localhost"`
+		Port int    `flag:"port"`
+	}
+	type database struct {
+		Primary conn   `flag:",prefix=primary."`
+		Replica *conn  `flag:",prefix=replica."`
+		Name    string `flag:"name"`
+	}
+	type test struct {
+		Database database `flag:",prefix=db."`
+		Cache    conn     `flag:",prefix=cache-"`
+		Port     int      `flag:"port"`
+	}
+	args := []string{
+		"-db.primary.host=primary.example.com",
+		"-db.primary.port=5432",
+		"-db.replica.port=5433",
+		"-db.name=app",
+		"-cache-port=6379",
+		"-port=80",
+	}
+
+	ts := test{Database: database{Replica: &conn{}}}
+	if err := (&Parser{Args: args}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{
+		Database: database{
+			Primary: conn{Host: "primary.example.com", Port: 5432},
+			Replica: &conn{Host: "localhost", Port: 5433},
+			Name:    "app",
+		},
+		Cache: conn{Host: "localhost", Port: 6379},
+		Port:  80,
+	}
+	if !reflect.DeepEqual(ts, expected) {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+}
+
+type listener struct {
+	Host string `flag:"host"`
+	Port int    `flag:"port"`
+	Addr string
+}
+
+func (l *listener) AfterDecode() error {
+	if l.Port < 0 {
+		return fmt.Errorf("invalid port %d", l.Port)
+	}
+	l.Addr = fmt.Sprintf("%s:%d", l.Host, l.Port)
+	return nil
+}
+
+type server struct {
+	Listener listener `flag:",prefix=listen-"`
+	Name     string   `flag:"name"`
+	Summary  string
+}
+
+func (s *server) AfterDecode() error {
+	s.Summary = s.Name + "@" + s.Listener.Addr
+	return nil
+}
+
+func TestAfterDecode(t *testing.T) {
+	var s server
+	p := Parser{Args: []string{"-listen-host=localhost", "-listen-port=8080", "-name=api"}}
+	if err := p.Decode(&s); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if s.Listener.Addr != "localhost:8080" || s.Summary != "api@localhost:8080" {
+		t.Errorf("wrong derived fields got %s and %s", s.Listener.Addr, s.Summary)
+	}
+
+	p.Args = []string{"-listen-port=-1"}
+	if err := p.Decode(&s); err == nil || err.Error() != "invalid port -1" {
+		t.Errorf("wrong error expected invalid port -1 got %v", err)
+	}
+}
+
+type node struct {
+	Name string `flag:"name"`
+	Next *node
+}
+
+func TestMaxDepth(t *testing.T) {
+	cycle := &node{}
+	cycle.Next = cycle
+
+	expected := "flagstruct: maximum depth of 64 nested structs exceeded at `flagstruct.node`"
+	if err := (&Parser{Args: []string{"-name=a"}}).Decode(cycle); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected %s got %v", expected, err)
+	}
+	if err := (&Parser{Args: []string{"-name=a"}}).Validate(cycle); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected %s got %v", expected, err)
+	}
+
+	list := &node{Next: &node{Next: &node{}}}
+	p := Parser{Args: []string{"-name=a"}, MaxDepth: 3}
+	if err := p.Decode(list); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	p.MaxDepth = 2
+	if err := p.Decode(list); err == nil {
+		t.Error("expected error for a list deeper than the maximum depth")
+	}
+}
+
+func TestOSDefaults(t *testing.T) {
+	type config struct {
+		LogDir string `flag:"log-dir,default=/tmp/app,default-linux=/var/log/app,default-windows=C:\\logs\\app"`
+		Shell  string `flag:"shell,default-windows=cmd.exe"`
+	}
+
+	type test struct {
+		goos   string
+		args   []string
+		logDir string
+		shell  string
+	}
+
+	tests := []*test{
+		{goos: "linux", logDir: "/var/log/app"},
+		{goos: "windows", logDir: `C:\logs\app`, shell: "cmd.exe"},
+		{goos: "darwin", logDir: "/tmp/app"},
+		{goos: "windows", args: []string{"-log-dir=D:\\app", "-shell=pwsh"}, logDir: `D:\app`, shell: "pwsh"},
+	}
+
+	for i, ts := range tests {
+		var c config
+		if err := (&Parser{Args: ts.args, GOOS: ts.goos}).Decode(&c); err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if c.LogDir != ts.logDir || c.Shell != ts.shell {
+			t.Errorf("case #%d: wrong values expected %q %q got %q %q", i, ts.logDir, ts.shell, c.LogDir, c.Shell)
+		}
+	}
+
+	var c struct {
+		LogDir string `flag:"log-dir,required,default-linux=/var/log/app"`
+	}
+	if err := (&Parser{Args: []string{}}).Decode(&c); err != ErrInvalidAnnotation {
+		t.Errorf("expected %v, got %v", ErrInvalidAnnotation, err)
+	}
 }
@@ -0,0 +1,58 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// RegisterFunc registers the function fn under name, assigned to the fields
+// of its type annotated with ",func" when the flag value is name. It panics
+// when fn is not a function.
+//
+//	p.RegisterFunc("sha256", func(b []byte) []byte {
+//		sum := sha256.Sum256(b)
+//		return sum[:]
+//	})
+func (p *Parser) RegisterFunc(name string, fn interface{}) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		panic(fmt.Sprintf("flagstruct: RegisterFunc of non-func type %T", fn))
+	}
+	if p.funcs == nil {
+		p.funcs = make(map[reflect.Type]map[string]reflect.Value)
+	}
+	if p.funcs[v.Type()] == nil {
+		p.funcs[v.Type()] = make(map[string]reflect.Value)
+	}
+	p.funcs[v.Type()][name] = v
+}
+
+// decodeFunc assigns to f the function registered for its type as flagVal,
+// failing with the registered names otherwise.
+func (p *Parser) decodeFunc(f *reflect.Value, flagVal string) error {
+	if f.Kind() != reflect.Func {
+		return fmt.Errorf("option `func` requires a function field, got `%v`", f.Type())
+	}
+	funcs := p.funcs[f.Type()]
+	if funcs == nil {
+		// functions registered as literals are assignable to named types
+		for t, registered := range p.funcs {
+			if t.AssignableTo(f.Type()) {
+				funcs = registered
+				break
+			}
+		}
+	}
+	fn, ok := funcs[flagVal]
+	if !ok {
+		names := make([]string, 0, len(funcs))
+		for name := range funcs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("no function registered as `%s` for type `%v`, instead use %+v", flagVal, f.Type(), names)
+	}
+	f.Set(fn)
+	return nil
+}
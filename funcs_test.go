@@ -0,0 +1,56 @@
+package flagstruct
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+type hashFunc func([]byte) []byte
+
+func TestDecodeFunc(t *testing.T) {
+	var s struct {
+		Hash  hashFunc         `flag:"hash,func"`
+		Round func(int) int    `flag:"round,func,default=up"`
+		Other func(string) int `flag:"other,func"`
+	}
+
+	p := Parser{Args: []string{"-hash=sha256"}}
+	p.RegisterFunc("sha256", func(b []byte) []byte {
+		sum := sha256.Sum256(b)
+		return sum[:]
+	})
+	p.RegisterFunc("md5", func(b []byte) []byte {
+		sum := md5.Sum(b)
+		return sum[:]
+	})
+	p.RegisterFunc("up", func(n int) int { return (n + 9) / 10 * 10 })
+	p.RegisterFunc("down", func(n int) int { return n / 10 * 10 })
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if got := fmt.Sprintf("%x", s.Hash([]byte("abc"))); got != fmt.Sprintf("%x", sha256.Sum256([]byte("abc"))) {
+		t.Errorf("expected the sha256 function, got a hash `%s`", got)
+	}
+	if s.Round(11) != 20 || s.Other != nil {
+		t.Errorf("wrong functions decoded, rounding 11 to %d", s.Round(11))
+	}
+
+	p.Args = []string{"-hash=md5", "-round=down"}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if got := fmt.Sprintf("%x", s.Hash([]byte("abc"))); got != fmt.Sprintf("%x", md5.Sum([]byte("abc"))) {
+		t.Errorf("expected the md5 function, got a hash `%s`", got)
+	}
+	if s.Round(11) != 10 {
+		t.Errorf("expected the down function, rounding 11 to %d", s.Round(11))
+	}
+
+	p.Args = []string{"-hash=sha1"}
+	expected := "flagstruct: could not decode value `sha1` to kind `func`: no function registered as `sha1` for type `flagstruct.hashFunc`, instead use [md5 sha256]"
+	if err := p.Decode(&s); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected `%s` got `%v`", expected, err)
+	}
+}
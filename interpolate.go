@@ -0,0 +1,54 @@
+package flagstruct
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var referencePattern = regexp.MustCompile(`\{\{([A-Za-z0-9][A-Za-z0-9_.-]*)\}\}`)
+
+// interpolate substitutes the `{{name}}` references of the given values with
+// the interpolated value of the referenced flag, failing on cyclic
+// references.
+func interpolate(values map[string]string) (map[string]string, error) {
+	interpolated := make(map[string]string, len(values))
+	var visit func(name string, path []string) (string, error)
+	visit = func(name string, path []string) (string, error) {
+		if v, ok := interpolated[name]; ok {
+			return v, nil
+		}
+		if i := indexOf(path, name); i >= 0 {
+			cycle := append(append([]string(nil), path[i:]...), name)
+			return "", fmt.Errorf("flagstruct: cyclic interpolation of flag '%s' (%s)", name, strings.Join(cycle, " -> "))
+		}
+		path = append(path, name)
+		var err error
+		v := referencePattern.ReplaceAllStringFunc(values[name], func(ref string) string {
+			ref = ref[2 : len(ref)-2]
+			if _, ok := values[ref]; !ok || err != nil {
+				return "{{" + ref + "}}"
+			}
+			var r string
+			r, err = visit(ref, path)
+			return r
+		})
+		if err != nil {
+			return "", err
+		}
+		interpolated[name] = v
+		return v, nil
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return interpolated, nil
+}
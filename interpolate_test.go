@@ -0,0 +1,73 @@
+package flagstruct
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	values := map[string]string{
+		"host":    "example.com",
+		"port":    "8080",
+		"addr":    "{{host}}:{{port}}",
+		"url":     "https://{{addr}}/{{missing}}",
+		"greet":   "Hello {{.User}}",
+		"literal": "{{ host }}",
+	}
+	result, err := interpolate(values)
+	if err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	expected := map[string]string{
+		"host":    "example.com",
+		"port":    "8080",
+		"addr":    "example.com:8080",
+		"url":     "https://example.com:8080/{{missing}}",
+		"greet":   "Hello {{.User}}",
+		"literal": "{{ host }}",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("wrong interpolation expected %v got %v", expected, result)
+	}
+
+	cyclic := map[string]string{"a": "{{b}}", "b": "x{{c}}", "c": "{{a}}", "d": "{{d}}"}
+	expectedErr := "flagstruct: cyclic interpolation of flag 'a' (a -> b -> c -> a)"
+	if _, err := interpolate(cyclic); err == nil || err.Error() != expectedErr {
+		t.Errorf("wrong error expected %s got %v", expectedErr, err)
+	}
+}
+
+func TestParserInterpolate(t *testing.T) {
+	type server struct {
+		Host string `flag:"host,default=localhost"`
+		Port int    `flag:"port"`
+	}
+	type test struct {
+		Server server `flag:",prefix=server-"`
+		URL    string `flag:"url,default=https://{{server-host}}:{{server-port}}/api"`
+		Env    string `flag:"env,allowed=dev-eu;prod-eu"`
+		Region string `flag:"region,default=eu"`
+	}
+
+	var ts test
+	p := Parser{Args: []string{"-server-port=8080", "-env=dev-{{region}}"}, Interpolate: true}
+	if err := p.Decode(&ts); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{Server: server{Host: "localhost", Port: 8080}, URL: "https://localhost:8080/api", Env: "dev-eu", Region: "eu"}
+	if ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	p.Args = []string{"-server-host={{url}}"}
+	expectedErr := "flagstruct: cyclic interpolation of flag 'server-host' (server-host -> url -> server-host)"
+	if err := p.Decode(&ts); err == nil || err.Error() != expectedErr {
+		t.Errorf("wrong error expected %s got %v", expectedErr, err)
+	}
+
+	ts = test{}
+	p = Parser{Args: []string{"-server-port=8080"}}
+	if err := p.Decode(&ts); err != nil || ts.URL != "https://{{server-host}}:{{server-port}}/api" {
+		t.Errorf("expected values to be kept without Interpolate, got %s (%v)", ts.URL, err)
+	}
+}
@@ -0,0 +1,68 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// RegisterMask registers the named bits of the integer type t, used to decode
+// the fields annotated with ",mask" by OR-ing the bits of the names present
+// in the flag, separated by `|` (or the value of the option, as in
+// ",mask=+").
+//
+//	p.RegisterMask(reflect.TypeOf(Perm(0)), map[string]uint64{
+//		"read":  1,
+//		"write": 2,
+//		"exec":  4,
+//	})
+func (p *Parser) RegisterMask(t reflect.Type, bits map[string]uint64) {
+	if p.masks == nil {
+		p.masks = make(map[reflect.Type]map[string]uint64)
+	}
+	p.masks[t] = bits
+}
+
+// decodeMask decodes the names of the bits present in flagVal into f.
+func (p *Parser) decodeMask(f *reflect.Value, flagVal string, a *annotation) error {
+	bits, ok := p.masks[f.Type()]
+	if !ok {
+		return fmt.Errorf("no mask registered for type `%v`", f.Type())
+	}
+	var mask uint64
+	for _, name := range strings.Split(flagVal, a.mask) {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		bit, ok := bits[name]
+		if !ok {
+			return fmt.Errorf("unknown bit `%s`, instead use %+v", name, maskNames(bits))
+		}
+		mask |= bit
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f.OverflowInt(int64(mask)) {
+			return fmt.Errorf("mask %d overflows `%v`", mask, f.Type())
+		}
+		f.SetInt(int64(mask))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f.OverflowUint(mask) {
+			return fmt.Errorf("mask %d overflows `%v`", mask, f.Type())
+		}
+		f.SetUint(mask)
+	default:
+		return fmt.Errorf("option `mask` requires an integer type, got `%v`", f.Type())
+	}
+	return nil
+}
+
+func maskNames(bits map[string]uint64) []string {
+	names := make([]string, 0, len(bits))
+	for name := range bits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
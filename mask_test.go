@@ -0,0 +1,49 @@
+package flagstruct
+
+import (
+	"reflect"
+	"testing"
+)
+
+type perm uint8
+
+func TestDecodeMask(t *testing.T) {
+	type test struct {
+		Perms perm `flag:"perms,mask"`
+		Plus  perm `flag:"plus,mask=+"`
+		Other int  `flag:"other,mask"`
+	}
+
+	type decode struct {
+		args     []string
+		expected test
+		err      string
+	}
+	tests := []*decode{
+		{args: []string{"-perms=read|write"}, expected: test{Perms: 3}},
+		{args: []string{"-perms=read|exec|read"}, expected: test{Perms: 5}},
+		{args: []string{"-plus=write+exec"}, expected: test{Plus: 6}},
+		{
+			args: []string{"-perms=read|delete"},
+			err:  "flagstruct: could not decode value `read|delete` to kind `uint8`: unknown bit `delete`, instead use [exec read write]",
+		},
+		{
+			args: []string{"-other=read"},
+			err:  "flagstruct: could not decode value `read` to kind `int`: no mask registered for type `int`",
+		},
+	}
+
+	p := Parser{}
+	p.RegisterMask(reflect.TypeOf(perm(0)), map[string]uint64{"read": 1, "write": 2, "exec": 4})
+	for i, ts := range tests {
+		var result test
+		p.Args = ts.args
+		err := p.Decode(&result)
+		if (ts.err == "" && err != nil) || (ts.err != "" && (err == nil || err.Error() != ts.err)) {
+			t.Errorf("case #%d: wrong error expected %s got %v", i, ts.err, err)
+		}
+		if ts.err == "" && result != ts.expected {
+			t.Errorf("case #%d: wrong assignment expected %+v got %+v", i, ts.expected, result)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package flagstruct
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NameStyle is the style used to infer flag names from struct field names.
+type NameStyle int
+
+const (
+	// KebabCase infers names like `max-retries` and `http-port`.
+	KebabCase NameStyle = iota
+	// SnakeCase infers names like `max_retries` and `http_port`.
+	SnakeCase
+	// CamelCase infers names like `maxRetries` and `httpPort`.
+	CamelCase
+)
+
+// infer returns the flag name of the given struct field name in the style.
+func (s NameStyle) infer(name string) string {
+	words := splitWords(name)
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if s == CamelCase && i > 0 {
+			w = strings.ToUpper(w[:1]) + w[1:]
+		}
+		words[i] = w
+	}
+	switch s {
+	case SnakeCase:
+		return strings.Join(words, "_")
+	case CamelCase:
+		return strings.Join(words, "")
+	}
+	return strings.Join(words, "-")
+}
+
+// splitWords splits a Go identifier into its words, keeping acronyms
+// together (e.g. `HTTPPort` is split into `HTTP` and `Port`).
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		if !unicode.IsUpper(runes[i]) {
+			continue
+		}
+		prev := runes[i-1]
+		acronymEnd := unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) || acronymEnd {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
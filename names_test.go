@@ -0,0 +1,130 @@
+package flagstruct
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	type test struct {
+		name     string
+		expected []string
+	}
+
+	tests := []*test{
+		{name: "Host", expected: []string{"Host"}},
+		{name: "MaxRetries", expected: []string{"Max", "Retries"}},
+		{name: "HTTPPort", expected: []string{"HTTP", "Port"}},
+		{name: "UserID", expected: []string{"User", "ID"}},
+		{name: "ID", expected: []string{"ID"}},
+		{name: "Port2Host", expected: []string{"Port2", "Host"}},
+		{name: "", expected: nil},
+	}
+
+	for i, ts := range tests {
+		if result := splitWords(ts.name); !reflect.DeepEqual(result, ts.expected) {
+			t.Errorf("case #%d: wrong result expected %v got %v", i, ts.expected, result)
+		}
+	}
+}
+
+func TestNameStyle(t *testing.T) {
+	type test struct {
+		name     string
+		style    NameStyle
+		expected string
+	}
+
+	tests := []*test{
+		{name: "MaxRetries", style: KebabCase, expected: "max-retries"},
+		{name: "HTTPPort", style: KebabCase, expected: "http-port"},
+		{name: "UserID", style: KebabCase, expected: "user-id"},
+		{name: "MaxRetries", style: SnakeCase, expected: "max_retries"},
+		{name: "HTTPPort", style: SnakeCase, expected: "http_port"},
+		{name: "MaxRetries", style: CamelCase, expected: "maxRetries"},
+		{name: "HTTPPort", style: CamelCase, expected: "httpPort"},
+		{name: "UserID", style: CamelCase, expected: "userId"},
+	}
+
+	for i, ts := range tests {
+		if result := ts.style.infer(ts.name); result != ts.expected {
+			t.Errorf("case #%d: wrong result expected %s got %s", i, ts.expected, result)
+		}
+	}
+}
+
+func TestParserInferNames(t *testing.T) {
+	type nested struct {
+		MaxRetries int
+	}
+	type test struct {
+		HTTPPort int
+		Host     string `flag:"server-host"`
+		Nested   nested
+	}
+
+	type decode struct {
+		style    NameStyle
+		args     []string
+		expected test
+	}
+	tests := []*decode{
+		{
+			style:    KebabCase,
+			args:     []string{"-http-port=80", "-server-host=a", "-max-retries=3"},
+			expected: test{HTTPPort: 80, Host: "a", Nested: nested{MaxRetries: 3}},
+		},
+		{
+			style:    SnakeCase,
+			args:     []string{"-http_port=80", "-max_retries=3"},
+			expected: test{HTTPPort: 80, Nested: nested{MaxRetries: 3}},
+		},
+		{
+			style:    CamelCase,
+			args:     []string{"-httpPort=80", "-maxRetries=3", "-http-port=1"},
+			expected: test{HTTPPort: 80, Nested: nested{MaxRetries: 3}},
+		},
+	}
+
+	for i, ts := range tests {
+		var result test
+		p := Parser{Args: ts.args, InferNames: true, NameStyle: ts.style}
+		if err := p.Decode(&result); err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if result != ts.expected {
+			t.Errorf("case #%d: wrong assignment expected %+v got %+v", i, ts.expected, result)
+		}
+	}
+}
+
+func TestParserNameFunc(t *testing.T) {
+	type database struct {
+		Host string `flag:"host"`
+	}
+	type test struct {
+		Database database `flag:",prefix=db."`
+		Port     int      `flag:"port,short=p"`
+		Level    string   `flag:"level,default=info"`
+	}
+
+	var fields []string
+	p := Parser{
+		Args: []string{"-acme.db.host=example.com", "--acme.port=8080", "-port=9090"},
+		NameFunc: func(fieldName, tagName string) string {
+			fields = append(fields, fieldName)
+			return "acme." + tagName
+		},
+	}
+	var ts test
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{Database: database{Host: "example.com"}, Port: 8080, Level: "info"}
+	if ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+	if !reflect.DeepEqual(fields, []string{"Host", "Port", "Level"}) {
+		t.Errorf("wrong field names got %v", fields)
+	}
+}
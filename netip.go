@@ -0,0 +1,35 @@
+//go:build go1.18
+// +build go1.18
+
+package flagstruct
+
+import (
+	"net/netip"
+	"reflect"
+)
+
+func init() {
+	versionedTypes[reflect.TypeOf(netip.Addr{})] = decodeAddr
+	versionedTypes[reflect.TypeOf(netip.Prefix{})] = decodePrefix
+}
+
+// decodeAddr decodes an IPv4 or IPv6 address into a netip.Addr field.
+func decodeAddr(f *reflect.Value, flagVal string) error {
+	v, err := netip.ParseAddr(flagVal)
+	if err != nil {
+		return err
+	}
+	f.Set(reflect.ValueOf(v))
+	return nil
+}
+
+// decodePrefix decodes an IP network in CIDR notation into a netip.Prefix
+// field.
+func decodePrefix(f *reflect.Value, flagVal string) error {
+	v, err := netip.ParsePrefix(flagVal)
+	if err != nil {
+		return err
+	}
+	f.Set(reflect.ValueOf(v))
+	return nil
+}
@@ -0,0 +1,50 @@
+//go:build go1.18
+// +build go1.18
+
+package flagstruct
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeNetIP(t *testing.T) {
+	type fields struct {
+		Addr   netip.Addr   `flag:"addr"`
+		Prefix netip.Prefix `flag:"prefix"`
+		Addrs  []netip.Addr `flag:"addrs"`
+	}
+
+	type test struct {
+		arg      string
+		field    int
+		expected interface{}
+		err      bool
+	}
+
+	tests := []*test{
+		{field: 0, arg: "-addr=192.168.1.10", expected: netip.MustParseAddr("192.168.1.10")},
+		{field: 0, arg: "-addr=2001:db8::1", expected: netip.MustParseAddr("2001:db8::1")},
+		{field: 0, arg: "-addr=fe80::1%eth0", expected: netip.MustParseAddr("fe80::1%eth0")},
+		{field: 0, arg: "-addr=256.1.1.1", err: true},
+		{field: 0, arg: "-addr=example.com", err: true},
+		{field: 1, arg: "-prefix=10.0.0.0/8", expected: netip.MustParsePrefix("10.0.0.0/8")},
+		{field: 1, arg: "-prefix=2001:db8::/32", expected: netip.MustParsePrefix("2001:db8::/32")},
+		{field: 1, arg: "-prefix=10.0.0.0/33", err: true},
+		{field: 1, arg: "-prefix=10.0.0.0", err: true},
+		{field: 2, arg: "-addrs=10.0.0.1;::1", expected: []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("::1")}},
+	}
+
+	for i, ts := range tests {
+		var s fields
+		err := (&Parser{Args: []string{ts.arg}, Strict: true}).Decode(&s)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+		f := reflect.ValueOf(s).Field(ts.field)
+		if !ts.err && !reflect.DeepEqual(f.Interface(), ts.expected) {
+			t.Errorf("case #%d: expected %v got %v", i, ts.expected, f)
+		}
+	}
+}
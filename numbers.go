@@ -0,0 +1,168 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// decodeNumber decodes flagVal into the numeric field f, handling the
+// numeric options of the annotation. It reports whether any of them applied,
+// otherwise the value is left to the primitive decoding.
+func decodeNumber(f *reflect.Value, flagVal string, a *annotation) (bool, error) {
+	grouped := a.grouped != "" && isNumber(f.Kind()) && f.Type() != durationType
+	if grouped {
+		flagVal = strings.Replace(flagVal, a.grouped, "", -1)
+	}
+	switch f.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if a.percent {
+			return true, decodePercent(f, flagVal)
+		}
+		if a.rate {
+			return true, decodeRate(f, flagVal)
+		}
+	case reflect.Int64:
+		if a.iso8601 && f.Type() == durationType {
+			d, err := parseISO8601Duration(flagVal)
+			if err != nil {
+				return true, err
+			}
+			f.SetInt(int64(d))
+			return true, nil
+		}
+		if a.defaultUnit != "" && f.Type() == durationType {
+			if _, err := strconv.ParseFloat(flagVal, 64); err == nil {
+				flagVal += a.defaultUnit
+			}
+			return true, decodePrimitive(f, flagVal, a)
+		}
+	}
+	if a.base != 0 && isInteger(f.Kind()) {
+		return true, decodeBase(f, flagVal, a)
+	}
+	if grouped {
+		return true, decodePrimitive(f, flagVal, a)
+	}
+	return false, nil
+}
+
+// basePrefixes holds the prefixes of the integer literals of each base,
+// which are accepted along with the unprefixed digits.
+var basePrefixes = map[int]string{2: "0b", 8: "0o", 16: "0x"}
+
+// decodeBase decodes the integer flagVal in the given base into f, stripping
+// the underscores grouping its digits and the prefix of the base (e.g.
+// `0xFF_00` and `FF_00` are both accepted in base 16). The bytes of base 16
+// digits are reversed when annotated with ",byteorder=le".
+func decodeBase(f *reflect.Value, flagVal string, a *annotation) error {
+	base := a.base
+	digits := strings.Replace(flagVal, "_", "", -1)
+	sign := ""
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		sign, digits = digits[:1], digits[1:]
+	}
+	if prefix, ok := basePrefixes[base]; ok && len(digits) > len(prefix) && strings.EqualFold(digits[:len(prefix)], prefix) {
+		digits = digits[len(prefix):]
+	}
+	if digits == "" || strings.HasPrefix(flagVal, "_") || strings.HasSuffix(flagVal, "_") {
+		return fmt.Errorf("`%s` is not a valid base %d integer", flagVal, base)
+	}
+	if a.byteOrder == "le" {
+		digits = reverseBytes(digits)
+	}
+	bits := f.Type().Bits()
+	switch f.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if sign == "-" {
+			return fmt.Errorf("`%s` is not a valid base %d unsigned integer", flagVal, base)
+		}
+		v, err := strconv.ParseUint(digits, base, bits)
+		if err != nil {
+			return baseError(err, flagVal)
+		}
+		f.SetUint(v)
+	default:
+		v, err := strconv.ParseInt(sign+digits, base, bits)
+		if err != nil {
+			return baseError(err, flagVal)
+		}
+		f.SetInt(v)
+	}
+	return nil
+}
+
+// baseError reports the error parsing the digits of flagVal, which were
+// stripped (or reversed), as an error parsing flagVal itself.
+func baseError(err error, flagVal string) error {
+	if e, ok := err.(*strconv.NumError); ok {
+		e.Num = flagVal
+	}
+	return err
+}
+
+// reverseBytes returns the hex digits with their bytes in reverse order, so
+// little-endian digits are parsed as big-endian ones (e.g. `3412` as `1234`).
+func reverseBytes(digits string) string {
+	if len(digits)%2 != 0 {
+		digits = "0" + digits
+	}
+	b := make([]byte, 0, len(digits))
+	for i := len(digits); i > 0; i -= 2 {
+		b = append(b, digits[i-2:i]...)
+	}
+	return string(b)
+}
+
+// isNumber reports whether k is an integer or floating point kind.
+func isNumber(k reflect.Kind) bool {
+	return isInteger(k) || k == reflect.Float32 || k == reflect.Float64
+}
+
+// isInteger reports whether k is a signed or unsigned integer kind.
+func isInteger(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// decodePercent decodes a percentage like `50%` into its ratio (0.5), values
+// without the percent sign are decoded as they are.
+func decodePercent(f *reflect.Value, flagVal string) error {
+	value := strings.TrimSuffix(flagVal, "%")
+	v, err := strconv.ParseFloat(value, f.Type().Bits())
+	if err != nil {
+		return err
+	}
+	if value != flagVal {
+		v /= 100
+	}
+	f.SetFloat(v)
+	return nil
+}
+
+// rateUnits holds the seconds of every unit of the rates.
+var rateUnits = map[string]float64{"ms": 0.001, "s": 1, "m": 60, "h": 3600, "d": 86400}
+
+// decodeRate decodes a rate like `100/s` or `5/m` into its value per second
+// (100 and 0.0833...), values without unit are taken as per second.
+func decodeRate(f *reflect.Value, flagVal string) error {
+	value, unit := flagVal, "s"
+	if i := strings.LastIndex(flagVal, "/"); i >= 0 {
+		value, unit = flagVal[:i], strings.TrimSpace(flagVal[i+1:])
+	}
+	seconds, ok := rateUnits[unit]
+	if !ok {
+		return fmt.Errorf("unknown unit `%s` of rate `%s`, expected one of ms, s, m, h or d", unit, flagVal)
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), f.Type().Bits())
+	if err != nil {
+		return err
+	}
+	f.SetFloat(v / seconds)
+	return nil
+}
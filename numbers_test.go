@@ -0,0 +1,244 @@
+package flagstruct
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDecodePercent(t *testing.T) {
+	type fields struct {
+		Rate    float64 `flag:"rate,percent"`
+		Rate32  float32 `flag:"rate32,percent"`
+		Plain   float64 `flag:"plain"`
+		Percent int     `flag:"percent,percent"`
+	}
+
+	type test struct {
+		arg      string
+		field    int
+		expected string
+		err      bool
+	}
+
+	tests := []*test{
+		{field: 0, arg: "-rate=50%", expected: "0.5"},
+		{field: 0, arg: "-rate=100%", expected: "1"},
+		{field: 0, arg: "-rate=0.25", expected: "0.25"},
+		{field: 0, arg: "-rate=1.5e-3%", expected: "1.5e-05"},
+		{field: 0, arg: "-rate=a%", err: true},
+		{field: 1, arg: "-rate32=12.5%", expected: "0.125"},
+		{field: 2, arg: "-plain=1.5e-3", expected: "0.0015"},
+		{field: 2, arg: "-plain=50%", err: true},
+		{field: 3, arg: "-percent=50%", err: true},
+	}
+
+	for i, ts := range tests {
+		var s fields
+		err := (&Parser{Args: []string{ts.arg}}).Decode(&s)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+		f := reflect.ValueOf(s).Field(ts.field)
+		if !ts.err && fmt.Sprintf("%v", f) != ts.expected {
+			t.Errorf("case #%d: expected %v got %v", i, ts.expected, f)
+		}
+	}
+}
+
+func TestDecodeGrouped(t *testing.T) {
+	type fields struct {
+		Amount  float64   `flag:"amount,grouped"`
+		Count   int       `flag:"count,grouped"`
+		Euros   float64   `flag:"euros,grouped=."`
+		Spaced  uint      `flag:"spaced,grouped= "`
+		Rate    float64   `flag:"rate,grouped,percent"`
+		Amounts []float64 `flag:"amounts,grouped"`
+		Plain   int       `flag:"plain"`
+	}
+
+	type test struct {
+		arg      string
+		field    int
+		expected string
+		err      bool
+	}
+
+	tests := []*test{
+		{field: 0, arg: "-amount=1,234.56", expected: "1234.56"},
+		{field: 0, arg: "-amount=1,234,567", expected: "1.234567e+06"},
+		{field: 0, arg: "-amount=12.5", expected: "12.5"},
+		{field: 1, arg: "-count=-1,000", expected: "-1000"},
+		{field: 1, arg: "-count=1,2a", err: true},
+		{field: 2, arg: "-euros=1.234.567", expected: "1.234567e+06"},
+		{field: 3, arg: "-spaced=1 000 000", expected: "1000000"},
+		{field: 4, arg: "-rate=1,250%", expected: "12.5"},
+		{field: 5, arg: "-amounts=1,000;2,500.5", expected: "[1000 2500.5]"},
+		{field: 6, arg: "-plain=1,000", err: true},
+	}
+
+	for i, ts := range tests {
+		var s fields
+		err := (&Parser{Args: []string{ts.arg}, Strict: true}).Decode(&s)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+		f := reflect.ValueOf(s).Field(ts.field)
+		if !ts.err && fmt.Sprintf("%v", f) != ts.expected {
+			t.Errorf("case #%d: expected %v got %v", i, ts.expected, f)
+		}
+	}
+}
+
+func TestDecodeBase(t *testing.T) {
+	type fields struct {
+		Reg    uint32  `flag:"reg,base=16"`
+		Offset int     `flag:"offset,base=16"`
+		Mask   uint8   `flag:"mask,base=2"`
+		Mode   int     `flag:"mode,base=8"`
+		Regs   []uint8 `flag:"regs,base=16"`
+	}
+
+	type test struct {
+		arg      string
+		field    int
+		expected string
+		err      bool
+	}
+
+	tests := []*test{
+		{field: 0, arg: "-reg=0xFF_00", expected: "65280"},
+		{field: 0, arg: "-reg=FF_00", expected: "65280"},
+		{field: 0, arg: "-reg=0Xdead_beef", expected: "3735928559"},
+		{field: 0, arg: "-reg=ff", expected: "255"},
+		{field: 0, arg: "-reg=0xFG", err: true},
+		{field: 0, arg: "-reg=-1", err: true},
+		{field: 0, arg: "-reg=_FF", err: true},
+		{field: 0, arg: "-reg=0x", err: true},
+		{field: 0, arg: "-reg=1_0000_0000", err: true},
+		{field: 1, arg: "-offset=-0x1_0", expected: "-16"},
+		{field: 2, arg: "-mask=0b1010_0101", expected: "165"},
+		{field: 2, arg: "-mask=102", err: true},
+		{field: 3, arg: "-mode=0o755", expected: "493"},
+		{field: 3, arg: "-mode=644", expected: "420"},
+		{field: 4, arg: "-regs=0x0A;FF;1_F", expected: "[10 255 31]"},
+	}
+
+	for i, ts := range tests {
+		var s fields
+		err := (&Parser{Args: []string{ts.arg}, Strict: true}).Decode(&s)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+		f := reflect.ValueOf(s).Field(ts.field)
+		if !ts.err && fmt.Sprintf("%v", f) != ts.expected {
+			t.Errorf("case #%d: expected %v got %v", i, ts.expected, f)
+		}
+	}
+
+	var u struct {
+		Reg int `flag:"reg,base=40"`
+	}
+	if err := (&Parser{Args: []string{"-reg=1"}}).Decode(&u); err == nil {
+		t.Error("expected an error with an invalid base")
+	}
+}
+
+func TestDecodeRate(t *testing.T) {
+	type test struct {
+		value    string
+		expected float64
+		err      bool
+	}
+
+	tests := []*test{
+		{value: "100/s", expected: 100},
+		{value: "5/m", expected: 5.0 / 60},
+		{value: "7200/h", expected: 2},
+		{value: "1.5/ms", expected: 1500},
+		{value: "86400/d", expected: 1},
+		{value: "25", expected: 25},
+		{value: " 10 / s", expected: 10},
+		{value: "10/w", err: true},
+		{value: "10/", err: true},
+		{value: "abc/s", err: true},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			Rate float64 `flag:"rate,rate"`
+		}
+		err := (&Parser{Args: []string{"-rate=" + ts.value}}).Decode(&s)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+		if !ts.err && math.Abs(s.Rate-ts.expected) > 1e-9 {
+			t.Errorf("case #%d: expected %v got %v", i, ts.expected, s.Rate)
+		}
+	}
+
+	var s struct {
+		Rates []float64 `flag:"rates,rate"`
+	}
+	if err := (&Parser{Args: []string{"-rates=60/m;2/s"}}).Decode(&s); err != nil || !reflect.DeepEqual(s.Rates, []float64{1, 2}) {
+		t.Errorf("wrong rates %v (%v)", s.Rates, err)
+	}
+}
+
+func TestDecodeByteOrder(t *testing.T) {
+	type test struct {
+		args     []string
+		be, le   uint32
+		expected string
+	}
+
+	tests := []*test{
+		{args: []string{"-be=0xDEADBEEF", "-le=0xDEADBEEF"}, be: 0xDEADBEEF, le: 0xEFBEADDE},
+		{args: []string{"-be=1234", "-le=1234"}, be: 0x1234, le: 0x3412},
+		{args: []string{"-be=abc", "-le=abc"}, be: 0xABC, le: 0xBC0A},
+		{args: []string{"-be=01_00", "-le=01_00"}, be: 0x100, le: 0x1},
+		{args: []string{"-le=0x1122334455"}, expected: "flagstruct: could not decode value `0x1122334455` to kind `uint32`: strconv.ParseUint: parsing \"0x1122334455\": value out of range"},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			BE uint32 `flag:"be,base=16,byteorder=be"`
+			LE uint32 `flag:"le,base=16,byteorder=le"`
+		}
+		err := (&Parser{Args: ts.args, Strict: true}).Decode(&s)
+		if ts.expected != "" {
+			if err == nil || err.Error() != ts.expected {
+				t.Errorf("case #%d: wrong error expected `%s` got `%v`", i, ts.expected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if s.BE != ts.be || s.LE != ts.le {
+			t.Errorf("case #%d: expected %#x and %#x got %#x and %#x", i, ts.be, ts.le, s.BE, s.LE)
+		}
+	}
+
+	var u struct {
+		Magic int `flag:"magic,byteorder=le"`
+	}
+	expected := "flagstruct: malformed annotation, `byteorder` requires `base=16`"
+	if err := (&Parser{}).Decode(&u); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected `%s` got `%v`", expected, err)
+	}
+	var v struct {
+		Magic int `flag:"magic,byteorder=be"`
+	}
+	if err := (&Parser{}).Decode(&v); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected `%s` got `%v`", expected, err)
+	}
+	var w struct {
+		Magic int `flag:"magic,base=16,byteorder=middle"`
+	}
+	expected = "flagstruct: malformed annotation, unknown byte order `middle`, expected `le` or `be`"
+	if err := (&Parser{}).Decode(&w); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected `%s` got `%v`", expected, err)
+	}
+}
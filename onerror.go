@@ -0,0 +1,39 @@
+package flagstruct
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// The policies of the `onerror` option, applied when the value of the flag
+// can not be decoded.
+const (
+	// onErrorFail returns the decoding error, as flags do by default.
+	onErrorFail = "fail"
+	// onErrorWarnDefault warns about the decoding error and decodes the
+	// default value of the flag instead.
+	onErrorWarnDefault = "warn-default"
+	// onErrorWarnSkip warns about the decoding error and leaves the field
+	// zero.
+	onErrorWarnSkip = "warn-skip"
+)
+
+// onError applies the `onerror` policy of the annotation to the error
+// decoding f, returning the error when the policy is to fail.
+func (p *Parser) onError(f *reflect.Value, a *annotation, err error) error {
+	if a.onError == "" || a.onError == onErrorFail {
+		return err
+	}
+	w := p.Warnings
+	if w == nil {
+		w = os.Stderr
+	}
+	f.Set(reflect.Zero(f.Type()))
+	if a.onError == onErrorWarnDefault && a.defaultValue != "" {
+		fmt.Fprintf(w, "%v, using default value `%s` of flag '%s'\n", err, a.defaultValue, a.name)
+		return p.decodeField(f, expandDefault(a.defaultValue), a)
+	}
+	fmt.Fprintf(w, "%v, skipping flag '%s'\n", err, a.name)
+	return nil
+}
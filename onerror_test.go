@@ -0,0 +1,48 @@
+package flagstruct
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOnError(t *testing.T) {
+	var s struct {
+		Default int    `flag:"default,default=10,onerror=warn-default"`
+		Skip    int    `flag:"skip,default=10,onerror=warn-skip"`
+		Valid   int    `flag:"valid,default=10,onerror=warn-default"`
+		Name    string `flag:"name"`
+	}
+
+	var w bytes.Buffer
+	p := Parser{Args: []string{"-default=abc", "-skip=abc", "-valid=3", "-name=app"}, Warnings: &w}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if s.Default != 10 || s.Skip != 0 || s.Valid != 3 || s.Name != "app" {
+		t.Errorf("wrong values decoded: %+v", s)
+	}
+	warnings := strings.Split(strings.TrimSpace(w.String()), "\n")
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %q", warnings)
+	}
+	for i, expected := range []string{"using default value `10` of flag 'default'", "skipping flag 'skip'"} {
+		if !strings.HasSuffix(warnings[i], expected) {
+			t.Errorf("wrong warning expected suffix %q got %q", expected, warnings[i])
+		}
+	}
+
+	var f struct {
+		Retries int `flag:"retries,default=10,onerror=fail"`
+	}
+	if err := (&Parser{Args: []string{"-retries=abc"}}).Decode(&f); err == nil {
+		t.Error("expected an error with the fail policy")
+	}
+
+	var u struct {
+		Retries int `flag:"retries,onerror=ignore"`
+	}
+	if err := (&Parser{Args: []string{"-retries=1"}}).Decode(&u); err == nil {
+		t.Error("expected an error with an unknown policy")
+	}
+}
@@ -0,0 +1,71 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// RegisterParser registers a named parser, used to decode the fields
+// annotated with ",parser=<name>". The value returned by the parser is
+// assigned to the field, so its type must be assignable (or convertible) to
+// the field type.
+//
+//	p.RegisterParser("hostport", func(raw string) (interface{}, error) {
+//		host, port, err := net.SplitHostPort(raw)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return HostPort{Host: host, Port: port}, nil
+//	})
+func (p *Parser) RegisterParser(name string, fn func(raw string) (interface{}, error)) {
+	if p.parsers == nil {
+		p.parsers = make(map[string]func(raw string) (interface{}, error))
+	}
+	p.parsers[name] = fn
+}
+
+// decodeParsed decodes flagVal into f using the parser named by the
+// annotation.
+func (p *Parser) decodeParsed(f *reflect.Value, flagVal string, a *annotation) error {
+	parse, ok := p.parsers[a.parser]
+	if !ok {
+		return fmt.Errorf("no parser registered as `%s`", a.parser)
+	}
+	v, err := parse(flagVal)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case !rv.IsValid():
+		f.Set(reflect.Zero(f.Type()))
+	case rv.Type().AssignableTo(f.Type()):
+		f.Set(rv)
+	case rv.Type().ConvertibleTo(f.Type()):
+		f.Set(rv.Convert(f.Type()))
+	default:
+		return fmt.Errorf("parser `%s` returned `%v`, which can not be assigned to `%v`", a.parser, rv.Type(), f.Type())
+	}
+	return nil
+}
+
+// decodeSetter decodes flagVal into f calling the method named by the
+// `setter` option of the annotation, which must be of the form
+// `func(string) error`.
+func decodeSetter(f *reflect.Value, flagVal string, a *annotation) error {
+	m := f.Addr().MethodByName(a.setter)
+	if !m.IsValid() {
+		return fmt.Errorf("method `%s` not found on `%v`", a.setter, f.Addr().Type())
+	}
+	t := m.Type()
+	if t.NumIn() != 1 || t.In(0).Kind() != reflect.String || t.NumOut() != 1 || t.Out(0) != errorType {
+		return fmt.Errorf("method `%s` of `%v` must be of the form `func(string) error`, got `%v`", a.setter, f.Addr().Type(), t)
+	}
+	out := m.Call([]reflect.Value{reflect.ValueOf(flagVal).Convert(t.In(0))})
+	if err, _ := out[0].Interface().(error); err != nil {
+		return err
+	}
+	return nil
+}
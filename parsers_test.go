@@ -0,0 +1,129 @@
+package flagstruct
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+)
+
+type hostPort struct {
+	Host string
+	Port int
+}
+
+func TestRegisterParser(t *testing.T) {
+	type test struct {
+		Addr   hostPort  `flag:"addr,parser=hostport"`
+		Backup *hostPort `flag:"backup,parser=hostport-ptr"`
+		Port   int64     `flag:"port,parser=port"`
+	}
+
+	p := Parser{}
+	p.RegisterParser("hostport", func(raw string) (interface{}, error) {
+		host, port, err := net.SplitHostPort(raw)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, err
+		}
+		return hostPort{Host: host, Port: n}, nil
+	})
+	p.RegisterParser("hostport-ptr", func(raw string) (interface{}, error) {
+		return &hostPort{Host: raw}, nil
+	})
+	p.RegisterParser("port", func(raw string) (interface{}, error) {
+		return strconv.Atoi(raw)
+	})
+
+	var ts test
+	p.Args = []string{"-addr=example.com:8080", "-backup=backup.example.com", "-port=443"}
+	if err := p.Decode(&ts); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if ts.Addr != (hostPort{Host: "example.com", Port: 8080}) {
+		t.Errorf("wrong address got %+v", ts.Addr)
+	}
+	if ts.Backup == nil || ts.Backup.Host != "backup.example.com" {
+		t.Errorf("wrong backup got %+v", ts.Backup)
+	}
+	if ts.Port != 443 {
+		t.Errorf("wrong converted port expected 443 got %d", ts.Port)
+	}
+
+	p.Args = []string{"-addr=example.com"}
+	expected := "flagstruct: could not decode value `example.com` to kind `struct`: address example.com: missing port in address"
+	if err := p.Decode(&ts); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected %s got %v", expected, err)
+	}
+
+	// unregistered parsers fail even when the flag is not provided
+	var unknown struct {
+		Unknown string `flag:"unknown,parser=missing"`
+	}
+	p.Args = []string{}
+	expected = "flagstruct: malformed annotation, no parser registered as `missing` for flag 'unknown'"
+	if err := p.Decode(&unknown); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected %s got %v", expected, err)
+	}
+}
+
+// thirdParty mimics a type exposing setter methods without implementing any
+// of the decoding interfaces.
+type thirdParty struct {
+	value string
+}
+
+func (t *thirdParty) SetValue(v string) error {
+	if v == "invalid" {
+		return errors.New("invalid value")
+	}
+	t.value = v
+	return nil
+}
+
+func (t *thirdParty) SetCount(n int) error {
+	return nil
+}
+
+func (t *thirdParty) Reset() {}
+
+func TestSetterOption(t *testing.T) {
+	type test struct {
+		Value   thirdParty `flag:"value,setter=SetValue"`
+		Missing thirdParty `flag:"missing,setter=SetMissing"`
+		Count   thirdParty `flag:"count,setter=SetCount"`
+		Reset   thirdParty `flag:"reset,setter=Reset"`
+	}
+
+	type decode struct {
+		arg string
+		err string
+	}
+	tests := []*decode{
+		{arg: "-value=a"},
+		{arg: "-value=invalid", err: "flagstruct: could not decode value `invalid` to kind `struct`: invalid value"},
+		{arg: "-missing=a", err: "flagstruct: could not decode value `a` to kind `struct`: method `SetMissing` not found on `*flagstruct.thirdParty`"},
+		{
+			arg: "-count=1",
+			err: "flagstruct: could not decode value `1` to kind `struct`: method `SetCount` of `*flagstruct.thirdParty` must be of the form `func(string) error`, got `func(int) error`",
+		},
+		{
+			arg: "-reset=1",
+			err: "flagstruct: could not decode value `1` to kind `struct`: method `Reset` of `*flagstruct.thirdParty` must be of the form `func(string) error`, got `func()`",
+		},
+	}
+
+	for i, ts := range tests {
+		var result test
+		err := (&Parser{Args: []string{ts.arg}}).Decode(&result)
+		if (ts.err == "" && err != nil) || (ts.err != "" && (err == nil || err.Error() != ts.err)) {
+			t.Errorf("case #%d: wrong error expected %s got %v", i, ts.err, err)
+		}
+		if ts.err == "" && result.Value.value != "a" {
+			t.Errorf("case #%d: wrong assignment expected a got %s", i, result.Value.value)
+		}
+	}
+}
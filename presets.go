@@ -0,0 +1,80 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// RegisterPresets registers the named presets of the structs annotated with
+// ",preset=<name>". The value of the flag selects the preset copied into the
+// struct, whose fields may still be overridden by their own flags.
+//
+//	p.RegisterPresets("tuning", map[string]interface{}{
+//		"fast": Tuning{Workers: 16, Batch: 512},
+//		"safe": Tuning{Workers: 1, Batch: 1, Fsync: true},
+//	})
+func (p *Parser) RegisterPresets(name string, presets map[string]interface{}) {
+	if p.presets == nil {
+		p.presets = make(map[string]map[string]interface{})
+	}
+	p.presets[name] = presets
+}
+
+// decodePreset copies the preset selected by the flag of the struct field f
+// into it, then decodes its nested flags over the preset values.
+func (p *Parser) decodePreset(s *state, f, d reflect.Value, ft reflect.StructField, prefix string) error {
+	a, err := parseAnnotation(ft.Tag.Get("flag"))
+	if err != nil {
+		return err
+	}
+	a.name = prefix + a.name
+	if p.NameFunc != nil {
+		a.name = p.NameFunc(ft.Name, a.name)
+	}
+	flagVal, err := p.resolve(s, a)
+	if m, ok := err.(*MissingError); ok && !s.dry {
+		s.missing = append(s.missing, m.Names...)
+	} else if err != nil && !s.dry {
+		return err
+	}
+	if flagVal != "" && !s.dry {
+		v, err := p.preset(f.Type(), flagVal, a)
+		if err != nil {
+			return err
+		}
+		f.Set(v)
+	}
+	s.values[a.name] = flagVal
+
+	preset := s.preset
+	s.preset = preset || flagVal != ""
+	defer func() { s.preset = preset }()
+	return p.decode(s, f, d, prefix+tagPrefix(ft.Tag.Get("flag")))
+}
+
+// preset returns the preset named flagVal of the annotation, as a value of
+// the type t.
+func (p *Parser) preset(t reflect.Type, flagVal string, a *annotation) (reflect.Value, error) {
+	presets, ok := p.presets[a.preset]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("flagstruct: no presets registered as `%s` for flag '%s'", a.preset, a.name)
+	}
+	x, ok := presets[flagVal]
+	if !ok {
+		names := make([]string, 0, len(presets))
+		for name := range presets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return reflect.Value{}, fmt.Errorf("flagstruct: unknown preset `%s` of flag '%s', instead use %+v%s", flagVal, a.name, names, suggestion(flagVal, names))
+	}
+	v := reflect.ValueOf(x)
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Type().Elem() == t {
+		v = v.Elem()
+	}
+	if !v.IsValid() || !v.Type().AssignableTo(t) {
+		return reflect.Value{}, fmt.Errorf("flagstruct: preset `%s` of flag '%s' is of type `%T`, expected `%v`", flagVal, a.name, x, t)
+	}
+	return v, nil
+}
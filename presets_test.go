@@ -0,0 +1,69 @@
+package flagstruct
+
+import (
+	"reflect"
+	"testing"
+)
+
+type tuning struct {
+	Workers int    `flag:"workers,default=4"`
+	Batch   int    `flag:"batch"`
+	Codec   string `flag:"codec"`
+}
+
+func TestPresets(t *testing.T) {
+	type test struct {
+		args     []string
+		tuning   tuning
+		expected string
+	}
+
+	tests := []*test{
+		{args: []string{"-profile=fast"}, tuning: tuning{Workers: 16, Batch: 512, Codec: "lz4"}},
+		{args: []string{"-profile=fast", "-tuning.batch=64"}, tuning: tuning{Workers: 16, Batch: 64, Codec: "lz4"}},
+		{args: []string{"-profile=safe", "-tuning.codec=zstd"}, tuning: tuning{Workers: 1, Batch: 1, Codec: "zstd"}},
+		{args: []string{"-tuning.batch=8"}, tuning: tuning{Workers: 4, Batch: 8}},
+		{args: []string{"-profile=fsat"}, expected: "flagstruct: unknown preset `fsat` of flag 'profile', instead use [fast safe] (did you mean 'fast'?)"},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			Tuning tuning `flag:"profile,preset=tuning,prefix=tuning."`
+		}
+		p := Parser{Args: ts.args}
+		p.RegisterPresets("tuning", map[string]interface{}{
+			"fast": tuning{Workers: 16, Batch: 512, Codec: "lz4"},
+			"safe": &tuning{Workers: 1, Batch: 1},
+		})
+		err := p.Decode(&s)
+		if ts.expected != "" {
+			if err == nil || err.Error() != ts.expected {
+				t.Errorf("case #%d: wrong error expected `%s` got `%v`", i, ts.expected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if !reflect.DeepEqual(s.Tuning, ts.tuning) {
+			t.Errorf("case #%d: wrong values expected %+v got %+v", i, ts.tuning, s.Tuning)
+		}
+	}
+}
+
+func TestPresetsInvalid(t *testing.T) {
+	var s struct {
+		Tuning tuning `flag:"profile,preset=tuning"`
+	}
+	p := Parser{Args: []string{"-profile=fast"}}
+	expected := "flagstruct: no presets registered as `tuning` for flag 'profile'"
+	if err := p.Decode(&s); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected `%s` got `%v`", expected, err)
+	}
+
+	p.RegisterPresets("tuning", map[string]interface{}{"fast": 16})
+	expected = "flagstruct: preset `fast` of flag 'profile' is of type `int`, expected `flagstruct.tuning`"
+	if err := p.Decode(&s); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected `%s` got `%v`", expected, err)
+	}
+}
@@ -0,0 +1,54 @@
+package flagstruct
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// prompt asks for the value of the flag, returning the line read from the
+// prompt reader, or an empty value when it could not be read. Flags already
+// answered in the batch of DecodeStream are not prompted again.
+func (p *Parser) prompt(s *state, a *annotation) string {
+	if v, ok := p.answers[a.name]; ok {
+		return v
+	}
+	v := p.readPrompt(s, a)
+	if p.answers != nil {
+		p.answers[a.name] = v
+	}
+	return v
+}
+
+// readPrompt writes the prompt of the flag and reads the answer.
+func (p *Parser) readPrompt(s *state, a *annotation) string {
+	if s.prompt == nil {
+		in := p.PromptIn
+		if in == nil {
+			if !isTerminal(os.Stdin) {
+				return ""
+			}
+			in = os.Stdin
+		}
+		s.prompt = bufio.NewReader(in)
+	}
+	out := p.PromptOut
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, "%s: ", a.name)
+	line, err := s.prompt.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return ""
+	}
+	return strings.TrimSpace(line)
+}
+
+// isTerminal reports whether the file is a character device, such as a
+// terminal, rather than a pipe or a regular file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,92 @@
+package flagstruct
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPromptMissing(t *testing.T) {
+	var s struct {
+		User string `flag:"user,required"`
+		Port int    `flag:"port,required"`
+		Host string `flag:"host,default=localhost"`
+	}
+
+	var out bytes.Buffer
+	p := Parser{
+		Args:          []string{"-port=8080"},
+		PromptMissing: true,
+		PromptIn:      strings.NewReader("admin\n"),
+		PromptOut:     &out,
+	}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if s.User != "admin" || s.Port != 8080 || s.Host != "localhost" {
+		t.Errorf("wrong values decoded: %+v", s)
+	}
+	if out.String() != "user: " {
+		t.Errorf("wrong prompt expected %q got %q", "user: ", out.String())
+	}
+
+	s.User, s.Port = "", 0
+	out.Reset()
+	p.Args = nil
+	p.PromptIn = strings.NewReader("root\n 22 ")
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if s.User != "root" || s.Port != 22 {
+		t.Errorf("wrong values decoded: %+v", s)
+	}
+	if out.String() != "user: port: " {
+		t.Errorf("wrong prompt expected %q got %q", "user: port: ", out.String())
+	}
+
+	p.PromptIn = strings.NewReader("\n")
+	err := p.Decode(&s)
+	var missing *MissingError
+	if !errors.As(err, &missing) || len(missing.Names) != 2 {
+		t.Errorf("expected a missing error for both flags, got %v", err)
+	}
+
+	p.PromptIn = strings.NewReader("root\nabc\n")
+	if err := p.Decode(&s); err == nil {
+		t.Error("expected an error with an invalid prompted value")
+	}
+}
+
+func TestPromptMissingStream(t *testing.T) {
+	type test struct {
+		User string `flag:"user,required"`
+		Port int    `flag:"port,default=80"`
+	}
+
+	var out bytes.Buffer
+	p := Parser{
+		PromptMissing: true,
+		PromptIn:      strings.NewReader("admin\n"),
+		PromptOut:     &out,
+	}
+	ch := make(chan []string, 2)
+	ch <- []string{"-port=8080"}
+	ch <- []string{"-port=9090"}
+	close(ch)
+
+	var errs []error
+	var ts test
+	p.DecodeStream(&ts, ch, func(err error) {
+		errs = append(errs, err)
+	})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors with a valid case: %v", errs)
+	}
+	if expected := (test{User: "admin", Port: 9090}); ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+	if out.String() != "user: " {
+		t.Errorf("wrong prompt expected %q got %q", "user: ", out.String())
+	}
+}
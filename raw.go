@@ -0,0 +1,48 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// rawField is a string field annotated with ",raw", which receives the value
+// of the flag as provided by the user, while the field declaring the same
+// flag receives the decoded one.
+type rawField struct {
+	f    reflect.Value
+	name string
+}
+
+// recordRaw records the value of the flag as provided.
+func (s *state) recordRaw(name, flagVal string) {
+	if s.raw == nil {
+		s.raw = make(map[string]string)
+	}
+	s.raw[name] = flagVal
+}
+
+// setRaw sets every raw field to the value of its flag as provided, once the
+// fields of the struct holding them were decoded. The fields whose flag was
+// not resolved yet are kept pending, as it may be declared by a later struct.
+func (s *state) setRaw(raws []rawField) {
+	for _, r := range raws {
+		if v, ok := s.raw[r.name]; ok {
+			r.f.SetString(v)
+			continue
+		}
+		s.pendingRaw = append(s.pendingRaw, r)
+	}
+}
+
+// setPendingRaw sets the pending raw fields once every flag was resolved,
+// failing for the ones whose flag is not declared by any field.
+func (s *state) setPendingRaw() error {
+	for _, r := range s.pendingRaw {
+		v, ok := s.raw[r.name]
+		if !ok {
+			return fmt.Errorf("flagstruct: malformed annotation, no field declares the flag '%s' of the raw field", r.name)
+		}
+		r.f.SetString(v)
+	}
+	return nil
+}
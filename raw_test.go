@@ -0,0 +1,70 @@
+package flagstruct
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRaw(t *testing.T) {
+	type test struct {
+		args       []string
+		timeout    time.Duration
+		timeoutRaw string
+		port       int
+		portRaw    string
+	}
+
+	tests := []*test{
+		{args: []string{"-timeout=90", "-port=8080"}, timeout: 90 * time.Second, timeoutRaw: "90", port: 8080, portRaw: "8080"},
+		{args: []string{"-timeout=1.5m"}, timeout: 90 * time.Second, timeoutRaw: "1.5m", port: 80},
+		{args: []string{"-port=443"}, timeout: 30 * time.Second, port: 443, portRaw: "443"},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			TimeoutRaw string        `flag:"timeout,raw"`
+			Timeout    time.Duration `flag:"timeout,default=30,defaultunit=s"`
+			Port       int           `flag:"port,default=80"`
+			PortRaw    string        `flag:"port,raw"`
+		}
+		if err := (&Parser{Args: ts.args}).Decode(&s); err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if s.Timeout != ts.timeout || s.TimeoutRaw != ts.timeoutRaw || s.Port != ts.port || s.PortRaw != ts.portRaw {
+			t.Errorf("case #%d: wrong values got %+v", i, s)
+		}
+	}
+
+	var s struct {
+		Port    int `flag:"port"`
+		PortRaw int `flag:"port,raw"`
+	}
+	expected := "flagstruct: option `raw` of flag 'port' requires a string field"
+	if err := (&Parser{Args: []string{"-port=80"}}).Decode(&s); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected `%s` got `%v`", expected, err)
+	}
+}
+
+func TestRawUndeclared(t *testing.T) {
+	type diagnostics struct {
+		PortRaw string `flag:"port,raw"`
+	}
+	var s struct {
+		Diagnostics diagnostics
+		Port        int `flag:"port"`
+	}
+	if err := (&Parser{Args: []string{"-port=0x50"}}).Decode(&s); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if s.Port != 80 || s.Diagnostics.PortRaw != "0x50" {
+		t.Errorf("expected the raw value of a flag declared afterwards, got %+v", s)
+	}
+
+	var u struct {
+		Nope string `flag:"nope,raw"`
+	}
+	expected := "flagstruct: malformed annotation, no field declares the flag 'nope' of the raw field"
+	if err := (&Parser{Args: []string{"-nope=1"}}).Decode(&u); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected `%s` got `%v`", expected, err)
+	}
+}
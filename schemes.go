@@ -0,0 +1,51 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// RegisterScheme registers the factory of the Decoder assigned to the
+// interface fields whose value starts with prefix (e.g. `s3://`), the
+// longest registered prefix wins. The decoder returned by the factory is
+// then decoded from the whole value.
+//
+//	p.RegisterScheme("s3://", func() flagstruct.Decoder { return &S3Store{} })
+//	p.RegisterScheme("file://", func() flagstruct.Decoder { return &FileStore{} })
+func (p *Parser) RegisterScheme(prefix string, factory func() Decoder) {
+	if p.schemes == nil {
+		p.schemes = make(map[string]func() Decoder)
+	}
+	p.schemes[prefix] = factory
+}
+
+// decodeScheme assigns to the interface f the decoder of the scheme of
+// flagVal, decoded from flagVal.
+func (p *Parser) decodeScheme(f *reflect.Value, flagVal string) error {
+	prefixes := make([]string, 0, len(p.schemes))
+	for prefix := range p.schemes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	var match string
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(flagVal, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	if match == "" {
+		return fmt.Errorf("unknown scheme of `%s`, instead use %+v", flagVal, prefixes)
+	}
+	d := p.schemes[match]()
+	v := reflect.ValueOf(d)
+	if !v.IsValid() || !v.Type().AssignableTo(f.Type()) {
+		return fmt.Errorf("the decoder `%T` of scheme `%s` does not implement `%v`", d, match, f.Type())
+	}
+	if err := d.Decode(flagVal); err != nil {
+		return err
+	}
+	f.Set(v)
+	return nil
+}
@@ -0,0 +1,74 @@
+package flagstruct
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type store interface {
+	Location() string
+}
+
+type localStore struct{ path string }
+
+func (s *localStore) Decode(v string) error {
+	s.path = strings.TrimPrefix(v, "file://")
+	return nil
+}
+
+func (s *localStore) Location() string { return s.path }
+
+type bucketStore struct{ bucket, key string }
+
+func (s *bucketStore) Decode(v string) error {
+	parts := strings.SplitN(strings.TrimPrefix(v, "s3://"), "/", 2)
+	if len(parts) < 2 || parts[0] == "" {
+		return errors.New("expected `s3://bucket/key`")
+	}
+	s.bucket, s.key = parts[0], parts[1]
+	return nil
+}
+
+func (s *bucketStore) Location() string { return s.bucket + ":" + s.key }
+
+func TestDecodeScheme(t *testing.T) {
+	var s struct {
+		Primary store       `flag:"primary"`
+		Backup  store       `flag:"backup"`
+		Any     interface{} `flag:"any"`
+	}
+
+	p := Parser{Args: []string{"-primary=s3://data/config.json", "-backup=file:///etc/app.json", "-any=42"}}
+	p.RegisterScheme("s3://", func() Decoder { return &bucketStore{} })
+	p.RegisterScheme("file://", func() Decoder { return &localStore{} })
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if _, ok := s.Primary.(*bucketStore); !ok || s.Primary.Location() != "data:config.json" {
+		t.Errorf("wrong primary store %#v", s.Primary)
+	}
+	if _, ok := s.Backup.(*localStore); !ok || s.Backup.Location() != "/etc/app.json" {
+		t.Errorf("wrong backup store %#v", s.Backup)
+	}
+	if s.Any != "42" {
+		t.Errorf("expected empty interfaces to be decoded as usual, got %#v", s.Any)
+	}
+
+	type test struct {
+		arg      string
+		expected string
+	}
+
+	tests := []*test{
+		{arg: "-primary=http://example.com", expected: "unknown scheme of `http://example.com`, instead use [file:// s3://]"},
+		{arg: "-primary=s3://bucket", expected: "expected `s3://bucket/key`"},
+	}
+
+	for i, ts := range tests {
+		p.Args = []string{ts.arg}
+		if err := p.Decode(&s); err == nil || !strings.HasSuffix(err.Error(), ts.expected) {
+			t.Errorf("case #%d: wrong error expected suffix `%s` got `%v`", i, ts.expected, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package flagstruct
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// compareSemver compares two semantic versions, returning -1, 0 or 1 when a
+// is lower, equal or greater than b. A leading `v` and build metadata are
+// ignored, while missing minor or patch numbers are taken as zero.
+func compareSemver(a, b string) (int, error) {
+	va, err := parseSemver(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseSemver(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va.numbers {
+		if va.numbers[i] != vb.numbers[i] {
+			return compareInt(va.numbers[i], vb.numbers[i]), nil
+		}
+	}
+	return comparePrerelease(va.prerelease, vb.prerelease), nil
+}
+
+type semver struct {
+	numbers    [3]uint64
+	prerelease []string
+}
+
+func parseSemver(v string) (*semver, error) {
+	s := strings.TrimPrefix(v, "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	var sv semver
+	if i := strings.Index(s, "-"); i >= 0 {
+		sv.prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("flagstruct: malformed semantic version `%s`", v)
+	}
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("flagstruct: malformed semantic version `%s`", v)
+		}
+		sv.numbers[i] = n
+	}
+	return &sv, nil
+}
+
+// comparePrerelease compares prerelease identifiers, a version without them
+// has higher precedence than one with them.
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		na, errA := strconv.ParseUint(a[i], 10, 64)
+		nb, errB := strconv.ParseUint(b[i], 10, 64)
+		switch {
+		case errA == nil && errB == nil:
+			return compareInt(na, nb)
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		}
+		return strings.Compare(a[i], b[i])
+	}
+	return compareInt(uint64(len(a)), uint64(len(b)))
+}
+
+func compareInt(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+package flagstruct
+
+import "testing"
+
+func TestCompareSemver(t *testing.T) {
+	type test struct {
+		a, b     string
+		expected int
+		err      bool
+	}
+
+	tests := []*test{
+		{a: "1.0.0", b: "1.0.0", expected: 0},
+		{a: "v1.2.0", b: "1.10.0", expected: -1},
+		{a: "2.0", b: "1.9.9", expected: 1},
+		{a: "1", b: "1.0.0", expected: 0},
+		{a: "1.0.0-alpha", b: "1.0.0", expected: -1},
+		{a: "1.0.0-alpha.1", b: "1.0.0-alpha.beta", expected: -1},
+		{a: "1.0.0-beta.11", b: "1.0.0-beta.2", expected: 1},
+		{a: "1.0.0-rc.1", b: "1.0.0-rc.1.1", expected: -1},
+		{a: "1.0.0+build", b: "1.0.0", expected: 0},
+		{a: "1.a.0", b: "1.0.0", err: true},
+		{a: "1.0.0", b: "1.0.0.0", err: true},
+	}
+
+	for i, ts := range tests {
+		result, err := compareSemver(ts.a, ts.b)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+		if result != ts.expected {
+			t.Errorf("case #%d: wrong result expected %d got %d", i, ts.expected, result)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// splitQuoted splits value around every separator not enclosed in double
+// quotes (e.g. `"a;b";c` is split into `"a;b"` and `c`). Values holding
+// unbalanced quotes are split as if quotes were regular characters.
+func splitQuoted(value, sep string) []string {
+	if !strings.Contains(value, `"`) {
+		return strings.Split(value, sep)
+	}
+	var elements []string
+	quoted, start := false, 0
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '"':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(value[i:], sep):
+			elements = append(elements, value[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	if quoted {
+		return strings.Split(value, sep)
+	}
+	return append(elements, value[start:])
+}
+
+// unquote removes the double quotes enclosing element, if any.
+func unquote(element string) string {
+	if len(element) >= 2 && element[0] == '"' && element[len(element)-1] == '"' {
+		return element[1 : len(element)-1]
+	}
+	return element
+}
+
+// decodeArray decodes flagVal into the array f like a slice of the same
+// element type would be. The number of elements must match the array length,
+// unless the `pad` option allows fewer elements, leaving the rest zero
+// valued, or the `truncate` option allows more, discarding the extra ones.
+func (p *Parser) decodeArray(f *reflect.Value, flagVal string, a *annotation) error {
+	slice := reflect.New(reflect.SliceOf(f.Type().Elem())).Elem()
+	if err := p.decodeSlice(&slice, flagVal, a); err != nil {
+		return err
+	}
+	if err := normalizeSlice(&slice, a); err != nil {
+		return err
+	}
+	n := slice.Len()
+	switch {
+	case n < f.Len() && !a.pad:
+		return fmt.Errorf("expected %d elements, got %d (use the `pad` option to allow fewer)", f.Len(), n)
+	case n > f.Len() && !a.truncate:
+		return fmt.Errorf("expected %d elements, got %d (use the `truncate` option to allow more)", f.Len(), n)
+	}
+	array := reflect.New(f.Type()).Elem()
+	reflect.Copy(array, slice)
+	f.Set(array)
+	return nil
+}
+
+// normalizeSlice applies the `dedupe` and `sort` options of the annotation
+// to the decoded slice f, in that order.
+func normalizeSlice(f *reflect.Value, a *annotation) error {
+	if a.dedupe {
+		f.Set(dedupeSlice(*f))
+	}
+	if a.sort {
+		less := lessFunc(f.Type().Elem())
+		if less == nil {
+			return fmt.Errorf("option `sort` is not supported for elements of type `%v`", f.Type().Elem())
+		}
+		sort.SliceStable(f.Interface(), func(i, j int) bool {
+			return less(f.Index(i), f.Index(j))
+		})
+	}
+	return nil
+}
+
+// dedupeSlice returns a copy of slice without duplicated elements, keeping
+// the first occurrence of each one.
+func dedupeSlice(slice reflect.Value) reflect.Value {
+	unique := reflect.MakeSlice(slice.Type(), 0, slice.Len())
+	comparable := slice.Type().Elem().Comparable()
+	seen := make(map[interface{}]bool)
+	for i := 0; i < slice.Len(); i++ {
+		e := slice.Index(i)
+		if comparable {
+			if seen[e.Interface()] {
+				continue
+			}
+			seen[e.Interface()] = true
+		} else if containsValue(unique, e) {
+			continue
+		}
+		unique = reflect.Append(unique, e)
+	}
+	return unique
+}
+
+func containsValue(slice, e reflect.Value) bool {
+	for i := 0; i < slice.Len(); i++ {
+		if reflect.DeepEqual(slice.Index(i).Interface(), e.Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// lessFunc returns the ordering function of the elements of type t, nil
+// when they can not be sorted.
+func lessFunc(t reflect.Type) func(x, y reflect.Value) bool {
+	if t == timeType {
+		return func(x, y reflect.Value) bool {
+			return x.Interface().(time.Time).Before(y.Interface().(time.Time))
+		}
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(x, y reflect.Value) bool { return x.Int() < y.Int() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(x, y reflect.Value) bool { return x.Uint() < y.Uint() }
+	case reflect.Float32, reflect.Float64:
+		return func(x, y reflect.Value) bool { return x.Float() < y.Float() }
+	case reflect.String:
+		return func(x, y reflect.Value) bool { return x.String() < y.String() }
+	case reflect.Bool:
+		return func(x, y reflect.Value) bool { return !x.Bool() && y.Bool() }
+	}
+	return nil
+}
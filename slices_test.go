@@ -0,0 +1,196 @@
+package flagstruct
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeSlice(t *testing.T) {
+	type test struct {
+		Tags      []string        `flag:"tags,dedupe"`
+		Sorted    []string        `flag:"sorted,sort"`
+		Both      []string        `flag:"both,dedupe,sort"`
+		Ints      []int           `flag:"ints,dedupe,sort"`
+		Durations []time.Duration `flag:"durations,sort"`
+		Matrix    [][]int         `flag:"matrix,dedupe"`
+		Unsorted  [][]int         `flag:"unsorted,sort"`
+	}
+	args := []string{
+		"-tags=b;a;a;c;b",
+		"-sorted=b;a;a;c",
+		"-both=b;a;a;c;b",
+		"-ints=10;2;2;-1;10",
+		"-durations=1m;1s;1h",
+		"-matrix=1,2;3;1,2",
+	}
+
+	var ts test
+	if err := (&Parser{Args: args}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{
+		Tags:      []string{"b", "a", "c"},
+		Sorted:    []string{"a", "a", "b", "c"},
+		Both:      []string{"a", "b", "c"},
+		Ints:      []int{-1, 2, 10},
+		Durations: []time.Duration{time.Second, time.Minute, time.Hour},
+		Matrix:    [][]int{{1, 2}, {3}},
+	}
+	if !reflect.DeepEqual(ts, expected) {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	if err := (&Parser{Args: []string{"-unsorted=1;2"}}).Decode(&ts); err == nil {
+		t.Error("expected error for unsortable elements")
+	}
+}
+
+func TestDecodeArray(t *testing.T) {
+	type fields struct {
+		Exact   [3]int    `flag:"exact"`
+		Addr    [4]byte   `flag:"addr,pad"`
+		Names   [2]string `flag:"names,truncate"`
+		Both    [2]int    `flag:"both,pad,truncate"`
+		Deduped [2]int    `flag:"deduped,dedupe,sort"`
+	}
+
+	type test struct {
+		arg      string
+		field    int
+		expected interface{}
+		err      string
+	}
+
+	tests := []*test{
+		{field: 0, arg: "-exact=1;2;3", expected: [3]int{1, 2, 3}},
+		{field: 0, arg: "-exact=1;2", err: "flagstruct: could not decode value `1;2` to kind `array`: expected 3 elements, got 2 (use the `pad` option to allow fewer)"},
+		{field: 0, arg: "-exact=1;2;3;4", err: "flagstruct: could not decode value `1;2;3;4` to kind `array`: expected 3 elements, got 4 (use the `truncate` option to allow more)"},
+		{field: 1, arg: "-addr=1;2;3", expected: [4]byte{1, 2, 3, 0}},
+		{field: 1, arg: "-addr=10;0;0;1", expected: [4]byte{10, 0, 0, 1}},
+		{field: 1, arg: "-addr=1;2;3;4;5", err: "flagstruct: could not decode value `1;2;3;4;5` to kind `array`: expected 4 elements, got 5 (use the `truncate` option to allow more)"},
+		{field: 2, arg: "-names=a;b;c", expected: [2]string{"a", "b"}},
+		{field: 2, arg: "-names=a", err: "flagstruct: could not decode value `a` to kind `array`: expected 2 elements, got 1 (use the `pad` option to allow fewer)"},
+		{field: 3, arg: "-both=1", expected: [2]int{1, 0}},
+		{field: 3, arg: "-both=1;2;3", expected: [2]int{1, 2}},
+		{field: 4, arg: "-deduped=3;1;3", expected: [2]int{1, 3}},
+	}
+
+	for i, ts := range tests {
+		var s fields
+		err := (&Parser{Args: []string{ts.arg}}).Decode(&s)
+		if (ts.err == "" && err != nil) || (ts.err != "" && (err == nil || err.Error() != ts.err)) {
+			t.Errorf("case #%d: wrong error expected %s got %v", i, ts.err, err)
+		}
+		if f := reflect.ValueOf(s).Field(ts.field); ts.err == "" && !reflect.DeepEqual(f.Interface(), ts.expected) {
+			t.Errorf("case #%d: expected %v got %v", i, ts.expected, f)
+		}
+	}
+}
+
+func TestSplitQuoted(t *testing.T) {
+	type test struct {
+		value    string
+		sep      string
+		expected []string
+	}
+
+	tests := []*test{
+		{value: "a;b;c", sep: ";", expected: []string{"a", "b", "c"}},
+		{value: `"a;b";c`, sep: ";", expected: []string{`"a;b"`, "c"}},
+		{value: `c;"a;b"`, sep: ";", expected: []string{"c", `"a;b"`}},
+		{value: `"a||b"||c`, sep: "||", expected: []string{`"a||b"`, "c"}},
+		{value: `x"a;b"y;c`, sep: ";", expected: []string{`x"a;b"y`, "c"}},
+		{value: `"a;b;c`, sep: ";", expected: []string{`"a`, "b", "c"}},
+		{value: `"";a`, sep: ";", expected: []string{`""`, "a"}},
+	}
+
+	for i, ts := range tests {
+		if result := splitQuoted(ts.value, ts.sep); !reflect.DeepEqual(result, ts.expected) {
+			t.Errorf("case #%d: wrong result expected %q got %q", i, ts.expected, result)
+		}
+	}
+}
+
+func TestDecodeQuotedSlice(t *testing.T) {
+	var s struct {
+		Items  []string   `flag:"items"`
+		Pipes  []string   `flag:"pipes,sep=|"`
+		Matrix [][]string `flag:"matrix"`
+	}
+
+	p := Parser{Args: []string{`-items="a;b"; c ;"d"`, `-pipes="a|b"|c`, `-matrix=a,"b,c";d`}}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if expected := []string{"a;b", "c", "d"}; !reflect.DeepEqual(s.Items, expected) {
+		t.Errorf("wrong items expected %q got %q", expected, s.Items)
+	}
+	if expected := []string{"a|b", "c"}; !reflect.DeepEqual(s.Pipes, expected) {
+		t.Errorf("wrong pipes expected %q got %q", expected, s.Pipes)
+	}
+	if expected := [][]string{{"a", "b,c"}, {"d"}}; !reflect.DeepEqual(s.Matrix, expected) {
+		t.Errorf("wrong matrix expected %q got %q", expected, s.Matrix)
+	}
+
+	p.Args = []string{`-items="a;b`}
+	if err := p.Decode(&s); err != nil || !reflect.DeepEqual(s.Items, []string{`"a`, "b"}) {
+		t.Errorf("expected unbalanced quotes to be literal, got %q (%v)", s.Items, err)
+	}
+}
+
+func TestDedupeOrdered(t *testing.T) {
+	var s struct {
+		Hosts []string `flag:"hosts,dedupe-ordered"`
+		Ports []int    `flag:"ports,dedupe-ordered"`
+	}
+
+	p := Parser{Args: []string{"-hosts=c;a;c;b;a;d;b", "-ports=80", "-ports=443;80", "-ports=22;443"}}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if expected := []string{"c", "a", "b", "d"}; !reflect.DeepEqual(s.Hosts, expected) {
+		t.Errorf("wrong hosts expected %q got %q", expected, s.Hosts)
+	}
+	if expected := []int{80, 443, 22}; !reflect.DeepEqual(s.Ports, expected) {
+		t.Errorf("wrong ports expected %v got %v", expected, s.Ports)
+	}
+
+	var u struct {
+		Hosts []string `flag:"hosts,dedupe-ordered,sort"`
+	}
+	if err := (&Parser{Args: []string{"-hosts=b;a;b"}}).Decode(&u); err != nil || !reflect.DeepEqual(u.Hosts, []string{"a", "b"}) {
+		t.Errorf("expected the alias to compose with `sort` like `dedupe`, got %q (%v)", u.Hosts, err)
+	}
+}
+
+func TestDecodeSliceOfMaps(t *testing.T) {
+	var s struct {
+		Rows   []map[string]int    `flag:"rows"`
+		Custom []map[string]string `flag:"custom,sep=|,innersep=&"`
+	}
+
+	p := Parser{Args: []string{"-rows=a:1,b:2;a:3,b:4", "-custom=host:a&port:80|host:b"}, Strict: true}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if expected := []map[string]int{{"a": 1, "b": 2}, {"a": 3, "b": 4}}; !reflect.DeepEqual(s.Rows, expected) {
+		t.Errorf("wrong rows expected %v got %v", expected, s.Rows)
+	}
+	if expected := []map[string]string{{"host": "a", "port": "80"}, {"host": "b"}}; !reflect.DeepEqual(s.Custom, expected) {
+		t.Errorf("wrong custom rows expected %v got %v", expected, s.Custom)
+	}
+
+	p.Args = []string{"-rows=a:1,b:x;a:3"}
+	if err := p.Decode(&s); err == nil {
+		t.Error("expected an error with an invalid entry in strict mode")
+	}
+
+	p.Strict = false
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error in lenient mode: %v", err)
+	}
+	if expected := []map[string]int{{"a": 1}, {"a": 3}}; !reflect.DeepEqual(s.Rows, expected) {
+		t.Errorf("expected the invalid entry to be dropped, got %v", s.Rows)
+	}
+}
@@ -0,0 +1,170 @@
+package flagstruct
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Source is the interface implemented by the places flag values are resolved
+// from, like configuration files, when provided through Parser.Sources.
+type Source interface {
+	// Lookup returns the value of the given flag name, reporting whether the
+	// source holds it.
+	Lookup(name string) (string, bool)
+}
+
+// flagSource is implemented by the sources needing the whole annotation of
+// the flag, like its short alias or environment variable name.
+type flagSource interface {
+	lookupFlag(args []string, a *annotation) (string, bool)
+}
+
+// ArgsSource returns the source resolving flag values from the arguments of
+// the parser.
+func ArgsSource() Source {
+	return argsSource{}
+}
+
+// EnvSource returns the source resolving flag values from the environment
+// variables, named like in DecodeEnv.
+func EnvSource() Source {
+	return envSource{}
+}
+
+type argsSource struct{}
+
+func (argsSource) Lookup(name string) (string, bool) {
+	return argsSource{}.lookupFlag(os.Args[1:], newAnnotation(name))
+}
+
+func (argsSource) lookupFlag(args []string, a *annotation) (string, bool) {
+	values := lookupFlags(args, a.name, a.short, a.boolean)
+	if len(values) == 0 {
+		return "", false
+	}
+	a.occurrences = values
+	return values[0], true
+}
+
+type envSource struct{}
+
+func (envSource) Lookup(name string) (string, bool) {
+	return envSource{}.lookupFlag(nil, newAnnotation(name))
+}
+
+func (envSource) lookupFlag(_ []string, a *annotation) (string, bool) {
+	for _, name := range a.envNames() {
+		if v := os.Getenv(name); v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// MapSource is a Source holding flag values keyed by their name.
+type MapSource map[string]string
+
+// Lookup implements the interface `flagstruct.Source`.
+func (m MapSource) Lookup(name string) (string, bool) {
+	v, ok := m[name]
+	return v, ok
+}
+
+// ReaderSource reads a MapSource from lines of the form `name=value`, where
+// blank lines and lines starting with `#` are ignored.
+//
+//	# /etc/app.conf
+//	host=example.com
+//	port=8080
+func ReaderSource(r io.Reader) (MapSource, error) {
+	m := make(MapSource)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) < 2 {
+			return nil, fmt.Errorf("flagstruct: malformed line %d `%s`, expected `name=value`", n, line)
+		}
+		m[strings.TrimLeft(strings.TrimSpace(kv[0]), "-")] = strings.TrimSpace(kv[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FileSource reads a MapSource from the file at the given path, following the
+// format of ReaderSource.
+func FileSource(path string) (MapSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReaderSource(file)
+}
+
+// AddSource appends a source to the parser, overriding the values of the
+// sources added before it.
+//
+//	p.AddSource(system).AddSource(user).AddSource(flagstruct.EnvSource()).AddSource(flagstruct.ArgsSource())
+func (p *Parser) AddSource(s Source) *Parser {
+	p.Sources = append(p.Sources, s)
+	return p
+}
+
+// lookupSource returns the value of the flag held by the given source.
+func lookupSource(src Source, args []string, a *annotation) (string, bool) {
+	if fs, ok := src.(flagSource); ok {
+		return fs.lookupFlag(args, a)
+	}
+	return src.Lookup(a.name)
+}
+
+// Provided is a value provided for a flag, as reported by DecodeWithSources.
+type Provided struct {
+	// Source is the source holding the value, nil for the default value of
+	// the flag.
+	Source Source
+	// Value is the raw value held by the source.
+	Value string
+}
+
+// DecodeWithSources decodes command line arguments into the provided
+// target like Decode, additionally returning every value provided for each
+// flag (not just the decoded one), keyed by the flag name. The values are
+// sorted by precedence, so the first one is the decoded value, while the
+// remaining ones were overridden (e.g. an environment variable overridden
+// by a flag), which helps detecting redundant configuration. The default
+// value of the flag is reported last.
+func (p *Parser) DecodeWithSources(v interface{}) (map[string][]Provided, error) {
+	report := make(map[string][]Provided)
+	if err := p.decodeTarget(v, reflect.Value{}, report, false); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// provided returns every non-empty value provided for the flag, by the
+// precedence of its source. The lookups run over a copy of the annotation,
+// keeping the occurrences of the decoded value untouched.
+func (p *Parser) provided(args []string, a *annotation) []Provided {
+	var provided []Provided
+	for _, src := range p.sources() {
+		c := *a
+		if v, _ := lookupSource(src, args, &c); v != "" {
+			provided = append(provided, Provided{Source: src, Value: v})
+		}
+	}
+	if a.defaultValue != "" {
+		provided = append(provided, Provided{Value: a.defaultValue})
+	}
+	return provided
+}
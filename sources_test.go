@@ -0,0 +1,188 @@
+package flagstruct
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReaderSource(t *testing.T) {
+	content := "# comment\n\nhost = example.com\n--port=8080\nempty=\n"
+	m, err := ReaderSource(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	expected := map[string]string{"host": "example.com", "port": "8080", "empty": ""}
+	for k, v := range expected {
+		if value, ok := m.Lookup(k); !ok || value != v {
+			t.Errorf("wrong value of %s expected %s got %s", k, v, value)
+		}
+	}
+	if _, ok := m.Lookup("missing"); ok {
+		t.Error("expected missing name to be reported as absent")
+	}
+
+	_, err = ReaderSource(strings.NewReader("host=a\ninvalid\n"))
+	if expected := "flagstruct: malformed line 2 `invalid`, expected `name=value`"; err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected %s got %v", expected, err)
+	}
+}
+
+func TestParserSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagstruct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.conf")
+	if err := ioutil.WriteFile(path, []byte("host=file.example.com\nport=8080\nlevel=debug\nuser=file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := FileSource(path)
+	if err != nil {
+		t.Fatalf("unexpected error reading the file: %v", err)
+	}
+	defer unsetenv("USER_NAME")
+	setenv(t, "USER_NAME", "env")
+
+	type test struct {
+		Host    string `flag:"host,default=localhost"`
+		Port    int    `flag:"port"`
+		Level   string `flag:"level,allowed=debug;info"`
+		User    string `flag:"user,env=USER_NAME"`
+		Timeout string `flag:"timeout,default=1m"`
+	}
+
+	var ts test
+	p := Parser{Args: []string{"-port=9090", "-user=cli"}}
+	p.AddSource(MapSource{"host": "defaults.example.com", "level": "info"}).
+		AddSource(file).
+		AddSource(EnvSource()).
+		AddSource(ArgsSource())
+	if err := p.Decode(&ts); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{Host: "file.example.com", Port: 9090, Level: "debug", User: "cli", Timeout: "1m"}
+	if ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	ts = test{}
+	p.Args = []string{}
+	if err := p.Decode(&ts); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if ts.User != "env" || ts.Port != 8080 {
+		t.Errorf("wrong overrides expected env and 8080 got %s and %d", ts.User, ts.Port)
+	}
+
+	p.Sources = []Source{MapSource{"level": "trace"}}
+	if err := p.Decode(&ts); err == nil {
+		t.Error("expected error for a not allowed value of a source")
+	}
+}
+
+func TestDecodeWithSources(t *testing.T) {
+	defer unsetenv("APP_PORT")
+	setenv(t, "APP_PORT", "8080")
+
+	var s struct {
+		Port int    `flag:"port,env=APP_PORT,default=80"`
+		Host string `flag:"host,default=localhost"`
+		User string `flag:"user"`
+	}
+
+	p := Parser{Args: []string{"-port=9090"}, Precedence: []Origin{OriginArgs, OriginEnv}}
+	report, err := p.DecodeWithSources(&s)
+	if err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if s.Port != 9090 || s.Host != "localhost" {
+		t.Errorf("wrong values decoded: %+v", s)
+	}
+
+	expected := map[string][]Provided{
+		"port": {{Source: ArgsSource(), Value: "9090"}, {Source: EnvSource(), Value: "8080"}, {Value: "80"}},
+		"host": {{Value: "localhost"}},
+		"user": nil,
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("wrong report expected %+v got %+v", expected, report)
+	}
+
+	var r struct {
+		Port int `flag:"port,required"`
+	}
+	if _, err := (&Parser{Args: []string{}}).DecodeWithSources(&r); err == nil {
+		t.Error("expected an error with a missing required flag")
+	}
+
+	defer unsetenv("APP_TAGS")
+	setenv(t, "APP_TAGS", "a;b")
+	type tags struct {
+		Tags []string `flag:"tags,env=APP_TAGS"`
+	}
+	p = Parser{Args: []string{"-tags=x", "-tags=y"}, Precedence: []Origin{OriginEnv, OriginArgs}}
+	var decoded, reported tags
+	if err := p.Decode(&decoded); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if _, err := p.DecodeWithSources(&reported); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if expected := []string{"a", "b"}; !reflect.DeepEqual(decoded.Tags, expected) || !reflect.DeepEqual(reported.Tags, expected) {
+		t.Errorf("wrong values expected %v got %v and %v", expected, decoded.Tags, reported.Tags)
+	}
+}
+
+func TestRequiredFromSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagstruct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.conf")
+	if err := ioutil.WriteFile(path, []byte("token=from-file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := FileSource(path)
+	if err != nil {
+		t.Fatalf("unexpected error reading the file: %v", err)
+	}
+	defer unsetenv("APP_TOKEN")
+
+	type config struct {
+		Token string `flag:"token,required,env=APP_TOKEN"`
+	}
+
+	var c config
+	p := Parser{Args: []string{}}
+	p.AddSource(EnvSource()).AddSource(ArgsSource())
+	if err := p.Decode(&c); err == nil || err.Error() != "flagstruct: flag 'token' is missing" {
+		t.Errorf("wrong error expected flag 'token' to be missing got %v", err)
+	}
+
+	setenv(t, "APP_TOKEN", "from-env")
+	if err := p.Decode(&c); err != nil || c.Token != "from-env" {
+		t.Errorf("expected the environment to satisfy the required flag, got %q (%v)", c.Token, err)
+	}
+
+	unsetenv("APP_TOKEN")
+	c = config{}
+	p.Sources = nil
+	p.AddSource(file).AddSource(EnvSource()).AddSource(ArgsSource())
+	if err := p.Decode(&c); err != nil || c.Token != "from-file" {
+		t.Errorf("expected the file to satisfy the required flag, got %q (%v)", c.Token, err)
+	}
+
+	setenv(t, "APP_TOKEN", "from-env")
+	c = config{}
+	if err := (&Parser{Args: []string{}, Precedence: []Origin{OriginArgs, OriginEnv}}).Decode(&c); err != nil || c.Token != "from-env" {
+		t.Errorf("expected the environment to satisfy the required flag, got %q (%v)", c.Token, err)
+	}
+}
@@ -0,0 +1,28 @@
+package flagstruct
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+)
+
+// isSQLNull reports whether t is one of the database/sql nullable wrappers,
+// like sql.NullString or sql.NullInt64.
+func isSQLNull(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.PkgPath() == "database/sql" && strings.HasPrefix(t.Name(), "Null")
+}
+
+// decodeSQLNull scans flagVal into a database/sql nullable wrapper, which
+// sets its inner value and marks it as valid. sql.NullTime values are
+// parsed using the layout of the annotation.
+func decodeSQLNull(f *reflect.Value, flagVal string, a *annotation) error {
+	var src interface{} = flagVal
+	if f.Type().Name() == "NullTime" {
+		v, err := parseTime(flagVal, a.layout)
+		if err != nil {
+			return err
+		}
+		src = v
+	}
+	return f.Addr().Interface().(sql.Scanner).Scan(src)
+}
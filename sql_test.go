@@ -0,0 +1,48 @@
+package flagstruct
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestDecodeSQLNull(t *testing.T) {
+	type test struct {
+		Name    sql.NullString  `flag:"name"`
+		Port    sql.NullInt64   `flag:"port"`
+		Ratio   sql.NullFloat64 `flag:"ratio"`
+		Enabled sql.NullBool    `flag:"enabled"`
+		Since   sql.NullTime    `flag:"since,layout=2006-01-02"`
+	}
+
+	var ts test
+	if err := (&Parser{Args: []string{}}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with absent flags: %v", err)
+	}
+	if ts.Name.Valid || ts.Port.Valid || ts.Ratio.Valid || ts.Enabled.Valid || ts.Since.Valid {
+		t.Errorf("expected absent flags to be invalid, got %+v", ts)
+	}
+
+	args := []string{"-name=db", "-port=5432", "-ratio=0.5", "-enabled=true", "-since=2020-03-01"}
+	if err := (&Parser{Args: args}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{
+		Name:    sql.NullString{String: "db", Valid: true},
+		Port:    sql.NullInt64{Int64: 5432, Valid: true},
+		Ratio:   sql.NullFloat64{Float64: 0.5, Valid: true},
+		Enabled: sql.NullBool{Bool: true, Valid: true},
+		Since:   sql.NullTime{Time: time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+	}
+	if ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	ts = test{}
+	if err := (&Parser{Args: []string{"-port=a"}}).Decode(&ts); err == nil {
+		t.Error("expected error for an invalid sql.NullInt64 value")
+	}
+	if ts.Port.Valid {
+		t.Error("expected sql.NullInt64 to remain invalid after an error")
+	}
+}
@@ -0,0 +1,61 @@
+package flagstruct
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// stdinContents holds the content read from every standard input, keyed by
+// its reader, since it can only be read once, while Validate and Decode (or
+// every batch of DecodeStream) need it in turn.
+var stdinContents sync.Map
+
+// stdinContent is the content read once from a standard input.
+type stdinContent struct {
+	once    sync.Once
+	content []byte
+	err     error
+}
+
+// readStdin returns the content of the standard input (or Parser.Stdin),
+// which is read only once.
+func (p *Parser) readStdin() ([]byte, error) {
+	var in io.Reader = os.Stdin
+	if p.Stdin != nil {
+		in = p.Stdin
+	}
+	if !reflect.TypeOf(in).Comparable() {
+		return ioutil.ReadAll(in)
+	}
+	v, _ := stdinContents.LoadOrStore(in, &stdinContent{})
+	c := v.(*stdinContent)
+	c.once.Do(func() { c.content, c.err = ioutil.ReadAll(in) })
+	return c.content, c.err
+}
+
+// stdinValue returns the value of the flags annotated with ",stdin" whose
+// value is `-`, read from the standard input (or Parser.Stdin). Byte slices
+// hold the whole content, while other fields are decoded from the trimmed
+// content. The content is read once, and reused by later decodes using the
+// same reader. It is resolved before any other step (like the allowed
+// values or the transforms) inspects the value.
+func (p *Parser) stdinValue(a *annotation) (string, error) {
+	content, err := p.readStdin()
+	if err != nil {
+		return "", fmt.Errorf("flagstruct: could not read the value of flag '%s' from stdin: %v", a.name, err)
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "" {
+		return "", nil
+	}
+	a.piped = true
+	if a.bytes {
+		return string(content), nil
+	}
+	return value, nil
+}
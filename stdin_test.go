@@ -0,0 +1,105 @@
+package flagstruct
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeStdin(t *testing.T) {
+	var s struct {
+		Input  string `flag:"input,stdin"`
+		Dash   string `flag:"dash"`
+		Lines  []int  `flag:"lines,stdin"`
+		Binary []byte `flag:"binary,stdin"`
+	}
+
+	type test struct {
+		args   []string
+		stdin  string
+		verify func() bool
+	}
+
+	tests := []*test{
+		{args: []string{"-input=-", "-dash=-"}, stdin: "  hello world\n", verify: func() bool {
+			return s.Input == "hello world" && s.Dash == "-"
+		}},
+		{args: []string{"-lines=-"}, stdin: "1;2;3\n", verify: func() bool {
+			return len(s.Lines) == 3 && s.Lines[2] == 3
+		}},
+		{args: []string{"-binary=-"}, stdin: "raw\n\x00", verify: func() bool {
+			return string(s.Binary) == "raw\n\x00"
+		}},
+		{args: []string{"-input=value"}, stdin: "ignored", verify: func() bool {
+			return s.Input == "value"
+		}},
+	}
+
+	for i, ts := range tests {
+		s.Input, s.Dash, s.Lines, s.Binary = "", "", nil, nil
+		p := Parser{Args: ts.args, Stdin: strings.NewReader(ts.stdin)}
+		if err := p.Decode(&s); err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if !ts.verify() {
+			t.Errorf("case #%d: wrong values decoded: %+v", i, s)
+		}
+	}
+
+	var u struct {
+		Count int `flag:"count,stdin"`
+	}
+	if err := (&Parser{Args: []string{"-count=-"}, Stdin: strings.NewReader("abc")}).Decode(&u); err == nil {
+		t.Error("expected an error with an invalid value read from stdin")
+	}
+}
+
+func TestDecodeStdinOnce(t *testing.T) {
+	var s struct {
+		Input string `flag:"input,stdin,required"`
+	}
+	p := Parser{Args: []string{"-input=-"}, Stdin: strings.NewReader("hello\n")}
+	if err := p.Validate(&s); err != nil {
+		t.Fatalf("unexpected error validating: %v", err)
+	}
+	if err := p.Decode(&s); err != nil || s.Input != "hello" {
+		t.Errorf("expected the content read while validating to be decoded, got %q (%v)", s.Input, err)
+	}
+
+	s.Input = ""
+	ch := make(chan []string, 1)
+	ch <- []string{"-input=-"}
+	close(ch)
+	(&Parser{Stdin: strings.NewReader("streamed")}).DecodeStream(&s, ch, func(err error) {
+		t.Errorf("unexpected error %v", err)
+	})
+	if s.Input != "streamed" {
+		t.Errorf("wrong value decoded from stdin while streaming, got %q", s.Input)
+	}
+
+	p = Parser{Args: []string{"-input=-"}, Stdin: strings.NewReader(" \n")}
+	expected := "flagstruct: flag 'input' is missing"
+	if err := p.Decode(&s); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected `%s` got `%v`", expected, err)
+	}
+}
+
+func TestDecodeStdinSteps(t *testing.T) {
+	var s struct {
+		Mode  string `flag:"mode,stdin,allowed=a;b"`
+		Level string `flag:"level,stdin,pipe=upper,allowed=DEBUG;INFO"`
+	}
+	p := Parser{Args: []string{"-mode=-"}, Stdin: strings.NewReader("b\n")}
+	if err := p.Decode(&s); err != nil || s.Mode != "b" {
+		t.Errorf("expected the content read from stdin to be allowed, got %q (%v)", s.Mode, err)
+	}
+
+	p = Parser{Args: []string{"-level=-"}, Stdin: strings.NewReader(" debug\n")}
+	if err := p.Decode(&s); err != nil || s.Level != "DEBUG" {
+		t.Errorf("expected the content read from stdin to be transformed, got %q (%v)", s.Level, err)
+	}
+
+	p = Parser{Args: []string{"-mode=-"}, Stdin: strings.NewReader("c")}
+	if err := p.Decode(&s); err == nil {
+		t.Error("expected an error with a disallowed value read from stdin")
+	}
+}
@@ -0,0 +1,46 @@
+package flagstruct
+
+import "sync"
+
+// DecodeStream decodes every batch of arguments received from ch onto v,
+// using the Merge semantics, so each batch only overrides the flags it
+// contains. Batches that fail to decode are reported to onError (when not
+// nil) and leave v untouched. It blocks until ch is closed.
+//
+// When v implements sync.Locker (e.g. it embeds a sync.Mutex), it is locked
+// while a batch is validated and applied, so readers holding the same lock
+// always observe complete batches. Otherwise, it is up to the caller to
+// synchronize the access to v.
+func DecodeStream(v interface{}, ch <-chan []string, onError func(error)) {
+	new(Parser).DecodeStream(v, ch, onError)
+}
+
+// DecodeStream decodes every batch of arguments received from ch onto v,
+// following the same rules as the package level DecodeStream function. The
+// arguments of each batch replace the Args of the parser.
+func (p *Parser) DecodeStream(v interface{}, ch <-chan []string, onError func(error)) {
+	locker, _ := v.(sync.Locker)
+	for args := range ch {
+		bp := *p
+		bp.Args = args
+		bp.Merge = true
+		if err := bp.apply(v, locker); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// apply validates the batch before decoding it onto v, holding the lock
+// (when any) during the whole process. Values prompted while validating are
+// reused when decoding.
+func (p *Parser) apply(v interface{}, locker sync.Locker) error {
+	if locker != nil {
+		locker.Lock()
+		defer locker.Unlock()
+	}
+	p.answers = make(map[string]string)
+	if err := p.Validate(v); err != nil {
+		return err
+	}
+	return p.Decode(v)
+}
@@ -0,0 +1,95 @@
+package flagstruct
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type streamConfig struct {
+	sync.Mutex
+	Host  string `flag:"host,default=localhost"`
+	Port  int    `flag:"port,default=80"`
+	Level string `flag:"level,allowed=debug;info"`
+}
+
+func TestDecodeStream(t *testing.T) {
+	ch := make(chan []string, 4)
+	ch <- []string{"-host=example.com"}
+	ch <- []string{"-port=8080", "-level=debug"}
+	ch <- []string{"-host=ignored", "-level=trace"}
+	ch <- []string{"-level=info"}
+	close(ch)
+
+	var errs []error
+	c := streamConfig{Port: 1}
+	DecodeStream(&c, ch, func(err error) {
+		errs = append(errs, err)
+	})
+
+	if len(errs) != 1 {
+		t.Errorf("expected a single error for the invalid batch, got %v", errs)
+	}
+	if c.Host != "example.com" || c.Port != 8080 || c.Level != "info" {
+		t.Errorf("wrong merged assignment got %s, %d, %s", c.Host, c.Port, c.Level)
+	}
+}
+
+func TestParserMerge(t *testing.T) {
+	type test struct {
+		Host string `flag:"host,default=localhost"`
+		User string `flag:"user,required"`
+		Port int    `flag:"port,default=80"`
+	}
+
+	ts := test{Host: "example.com", User: "root"}
+	if err := (&Parser{Args: []string{}, Merge: true}).Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a merged required field: %v", err)
+	}
+	if expected := (test{Host: "example.com", User: "root", Port: 80}); ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	ts = test{Host: "example.com"}
+	if err := (&Parser{Args: []string{}, Merge: true}).Decode(&ts); err == nil {
+		t.Error("expected an error for a zero valued required field")
+	}
+}
+
+func TestParserClearOnEmpty(t *testing.T) {
+	type test struct {
+		Proxy   string            `flag:"proxy"`
+		Hosts   []string          `flag:"hosts"`
+		Labels  map[string]string `flag:"labels"`
+		Level   string            `flag:"level,default=info"`
+		Timeout int               `flag:"timeout"`
+	}
+
+	base := func() test {
+		return test{
+			Proxy:   "http://proxy:3128",
+			Hosts:   []string{"a", "b"},
+			Labels:  map[string]string{"env": "prod"},
+			Level:   "debug",
+			Timeout: 30,
+		}
+	}
+
+	ts := base()
+	p := Parser{Args: []string{"-proxy=", "-hosts=", "--labels=", "-level="}, Merge: true, ClearOnEmpty: true}
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if !reflect.DeepEqual(ts, test{Timeout: 30}) {
+		t.Errorf("wrong assignment expected cleared fields got %+v", ts)
+	}
+
+	ts = base()
+	p.ClearOnEmpty = false
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	if !reflect.DeepEqual(ts, base()) {
+		t.Errorf("wrong assignment expected %+v got %+v", base(), ts)
+	}
+}
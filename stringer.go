@@ -0,0 +1,77 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// defaultStringerBound is the number of candidate values looked up by the
+// `stringer` option when no bound is provided.
+const defaultStringerBound = 256
+
+// decodeStringer decodes the name flagVal into the integer f implementing
+// fmt.Stringer, looking for the value in [0, bound) whose String method
+// returns the name, where names shared by several values are ambiguous. The
+// lookup stops at the first value whose String method panics, like
+// table-indexed ones do for values out of the table.
+func decodeStringer(f *reflect.Value, flagVal string, bound int) error {
+	if !isInteger(f.Kind()) {
+		return fmt.Errorf("option `stringer` requires an integer type, got `%v`", f.Type())
+	}
+	candidate := reflect.New(f.Type())
+	stringer, ok := candidate.Interface().(fmt.Stringer)
+	if !ok {
+		return fmt.Errorf("option `stringer` requires a type implementing fmt.Stringer, got `%v`", f.Type())
+	}
+	var matches []int
+	var panicked error
+	for n := 0; n < bound && setCandidate(candidate.Elem(), n); n++ {
+		name, err := stringerName(stringer)
+		if err != nil {
+			panicked = fmt.Errorf("`%s` is not the name of any value of `%v` below %d, whose String method panicked: %v", flagVal, f.Type(), n, err)
+			break
+		}
+		if name == flagVal {
+			matches = append(matches, n)
+		}
+	}
+	switch {
+	case len(matches) == 1:
+		setCandidate(candidate.Elem(), matches[0])
+		f.Set(candidate.Elem())
+		return nil
+	case len(matches) > 1:
+		return fmt.Errorf("`%s` is the name of several values of `%v`: %v", flagVal, f.Type(), matches)
+	case panicked != nil:
+		return panicked
+	}
+	return fmt.Errorf("`%s` is not the name of any value of `%v`", flagVal, f.Type())
+}
+
+// stringerName returns the name of the stringer, recovering its String
+// method from panicking.
+func stringerName(s fmt.Stringer) (name string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return s.String(), nil
+}
+
+// setCandidate sets the integer v to n, reporting whether n fits in v.
+func setCandidate(v reflect.Value, n int) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.OverflowInt(int64(n)) {
+			return false
+		}
+		v.SetInt(int64(n))
+	default:
+		if v.OverflowUint(uint64(n)) {
+			return false
+		}
+		v.SetUint(uint64(n))
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package flagstruct
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type hue int
+
+const (
+	red hue = iota
+	green
+	blue
+)
+
+func (c hue) String() string {
+	switch c {
+	case red:
+		return "Red"
+	case green:
+		return "Green"
+	case blue:
+		return "Blue"
+	}
+	return "Unknown"
+}
+
+type severity uint8
+
+func (l severity) String() string {
+	if l%2 == 0 {
+		return "Even"
+	}
+	return [...]string{1: "Debug", 3: "Info", 5: "Warn"}[l%6]
+}
+
+type signal uint8
+
+var signalNames = [...]string{"Hup", "Int", "Quit"}
+
+func (s signal) String() string {
+	return signalNames[s]
+}
+
+func TestDecodeStringer(t *testing.T) {
+	var s struct {
+		Color   hue      `flag:"color,stringer"`
+		Palette []hue    `flag:"palette,stringer"`
+		Level   severity `flag:"level,stringer=6"`
+	}
+
+	p := Parser{Args: []string{"-color=Blue", "-palette=Green;Red", "-level=Warn"}}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if s.Color != blue || !reflect.DeepEqual(s.Palette, []hue{green, red}) || s.Level != 5 {
+		t.Errorf("wrong values decoded: %+v", s)
+	}
+
+	type test struct {
+		arg      string
+		expected string
+	}
+
+	tests := []*test{
+		{arg: "-color=Purple", expected: "flagstruct: could not decode value `Purple` to kind `int`: `Purple` is not the name of any value of `flagstruct.hue`"},
+		{arg: "-level=2", expected: "flagstruct: could not decode value `2` to kind `uint8`: `2` is not the name of any value of `flagstruct.severity`"},
+		{arg: "-level=Even", expected: "flagstruct: could not decode value `Even` to kind `uint8`: `Even` is the name of several values of `flagstruct.severity`: [0 2 4]"},
+	}
+
+	for i, ts := range tests {
+		p.Args = []string{ts.arg}
+		if err := p.Decode(&s); err == nil || err.Error() != ts.expected {
+			t.Errorf("case #%d: wrong error expected `%s` got `%v`", i, ts.expected, err)
+		}
+	}
+}
+
+func TestDecodeStringerTable(t *testing.T) {
+	var s struct {
+		Signal signal `flag:"signal,stringer"`
+	}
+	if err := (&Parser{Args: []string{"-signal=Quit"}}).Decode(&s); err != nil || s.Signal != 2 {
+		t.Errorf("wrong value decoded %v (%v)", s.Signal, err)
+	}
+
+	expected := "flagstruct: could not decode value `Kill` to kind `uint8`: `Kill` is not the name of any value of `flagstruct.signal` below 3, whose String method panicked: "
+	if err := (&Parser{Args: []string{"-signal=Kill"}}).Decode(&s); err == nil || !strings.HasPrefix(err.Error(), expected) {
+		t.Errorf("wrong error expected `%s` got `%v`", expected, err)
+	}
+}
@@ -0,0 +1,54 @@
+package flagstruct
+
+import "fmt"
+
+// suggestion returns the hint naming the allowed value closest to value by
+// Levenshtein distance (e.g. ` (did you mean 'info'?)`), or an empty string
+// when none is close enough to be a typo.
+func suggestion(value string, allowed []string) string {
+	best, bestDistance := "", -1
+	for _, candidate := range allowed {
+		d := levenshtein(value, candidate)
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	n := len([]rune(value))
+	if bestDistance <= 0 || bestDistance >= n || (bestDistance > 2 && bestDistance > n/3) {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean '%s'?)", best)
+}
+
+// levenshtein returns the number of single rune insertions, deletions and
+// substitutions turning a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
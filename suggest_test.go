@@ -0,0 +1,53 @@
+package flagstruct
+
+import "testing"
+
+func TestLevenshtein(t *testing.T) {
+	type test struct {
+		a, b     string
+		expected int
+	}
+
+	tests := []*test{
+		{a: "", b: "", expected: 0},
+		{a: "info", b: "info", expected: 0},
+		{a: "", b: "info", expected: 4},
+		{a: "inof", b: "info", expected: 2},
+		{a: "debg", b: "debug", expected: 1},
+		{a: "kitten", b: "sitting", expected: 3},
+		{a: "héllo", b: "hello", expected: 1},
+	}
+
+	for i, ts := range tests {
+		if d := levenshtein(ts.a, ts.b); d != ts.expected {
+			t.Errorf("case #%d: wrong distance between %q and %q expected %d got %d", i, ts.a, ts.b, ts.expected, d)
+		}
+	}
+}
+
+func TestAllowedSuggestion(t *testing.T) {
+	type test struct {
+		args     []string
+		expected string
+	}
+
+	tests := []*test{
+		{args: []string{"-level=inof"}, expected: "flagstruct: the provided value is not allowed, instead use [debug info warning] (did you mean 'info'?)"},
+		{args: []string{"-level=warnign"}, expected: "flagstruct: the provided value is not allowed, instead use [debug info warning] (did you mean 'warning'?)"},
+		{args: []string{"-level=verbose"}, expected: "flagstruct: the provided value is not allowed, instead use [debug info warning]"},
+		{args: []string{"-level=x"}, expected: "flagstruct: the provided value is not allowed, instead use [debug info warning]"},
+		{args: []string{"-roles=admin;gest"}, expected: "flagstruct: the element `gest` of flag 'roles' is not allowed, instead use [admin guest] (did you mean 'guest'?)"},
+		{args: []string{"-port=8081"}, expected: "flagstruct: the provided value is not allowed, instead use [80 8080]"},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			Level string   `flag:"level,allowed=debug;info;warning"`
+			Roles []string `flag:"roles,allowed=admin;guest"`
+			Port  int      `flag:"port,allowed=80;8080"`
+		}
+		if err := (&Parser{Args: ts.args}).Decode(&s); err == nil || err.Error() != ts.expected {
+			t.Errorf("case #%d: wrong error expected `%s` got `%v`", i, ts.expected, err)
+		}
+	}
+}
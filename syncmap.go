@@ -0,0 +1,59 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var (
+	syncMapType = reflect.TypeOf(sync.Map{})
+
+	// entryTypes holds the key and value types of *sync.Map entries, by the
+	// names accepted by the `keytype` and `valuetype` options.
+	entryTypes = map[string]reflect.Type{
+		"string":   reflect.TypeOf(""),
+		"int":      reflect.TypeOf(0),
+		"int64":    reflect.TypeOf(int64(0)),
+		"uint":     reflect.TypeOf(uint(0)),
+		"float64":  reflect.TypeOf(float64(0)),
+		"bool":     reflect.TypeOf(false),
+		"duration": durationType,
+	}
+)
+
+// decodeSyncMap decodes flagVal into the *sync.Map f like a map, storing
+// every entry with the key and value types of the `keytype` and `valuetype`
+// options (strings by default). A new map is always allocated, replacing
+// the current one like plain maps, so maps shared with copies of the target
+// are never written.
+func (p *Parser) decodeSyncMap(f *reflect.Value, flagVal string, a *annotation) error {
+	kt, ok := entryTypes[a.keyType]
+	if !ok {
+		return fmt.Errorf("unknown key type `%s`, instead use %+v", a.keyType, entryTypeNames())
+	}
+	vt, ok := entryTypes[a.valueType]
+	if !ok {
+		return fmt.Errorf("unknown value type `%s`, instead use %+v", a.valueType, entryTypeNames())
+	}
+	m := reflect.New(reflect.MapOf(kt, vt)).Elem()
+	if err := p.decodeMap(&m, flagVal, a); err != nil {
+		return err
+	}
+	sm := new(sync.Map)
+	for _, k := range m.MapKeys() {
+		sm.Store(k.Interface(), m.MapIndex(k).Interface())
+	}
+	f.Set(reflect.ValueOf(sm))
+	return nil
+}
+
+func entryTypeNames() []string {
+	names := make([]string, 0, len(entryTypes))
+	for name := range entryTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
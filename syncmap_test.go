@@ -0,0 +1,81 @@
+package flagstruct
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDecodeSyncMap(t *testing.T) {
+	var s struct {
+		Routes   *sync.Map `flag:"routes"`
+		Limits   *sync.Map `flag:"limits,keytype=int,valuetype=duration"`
+		Features *sync.Map `flag:"features,valuetype=bool"`
+		Unset    *sync.Map `flag:"unset"`
+	}
+
+	p := Parser{Args: []string{"-routes=/api:backend;/static:cdn", "-limits=1:1s;2:1m", "-features=a;b:false"}}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if s.Unset != nil {
+		t.Error("expected the map of a missing flag to stay nil")
+	}
+
+	expected := map[*sync.Map]map[interface{}]interface{}{
+		s.Routes:   {"/api": "backend", "/static": "cdn"},
+		s.Limits:   {1: time.Second, 2: time.Minute},
+		s.Features: {"a": true, "b": false},
+	}
+	for m, entries := range expected {
+		n := 0
+		m.Range(func(k, v interface{}) bool {
+			n++
+			if entries[k] != v {
+				t.Errorf("wrong value of key %v expected %v got %v", k, entries[k], v)
+			}
+			return true
+		})
+		if n != len(entries) {
+			t.Errorf("wrong number of entries expected %d got %d", len(entries), n)
+		}
+	}
+
+	existing := new(sync.Map)
+	existing.Store("/old", "legacy")
+	s.Routes = existing
+	p.Args = []string{"-routes=/new:backend"}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if v, ok := s.Routes.Load("/new"); s.Routes == existing || !ok || v != "backend" {
+		t.Error("expected the entries to be stored into a new map")
+	}
+	if _, ok := s.Routes.Load("/old"); ok {
+		t.Error("expected the entries of the existing map to be replaced")
+	}
+
+	s.Routes = existing
+	p = Parser{Args: []string{"-routes=/old:backend;/other:cdn", "-limits=x:1s"}, Strict: true}
+	if err := p.Validate(&s); err == nil {
+		t.Fatal("expected an error with an invalid key")
+	}
+	n := 0
+	existing.Range(func(k, v interface{}) bool {
+		n++
+		if k != "/old" || v != "legacy" {
+			t.Errorf("expected the existing map to be untouched, got %v:%v", k, v)
+		}
+		return true
+	})
+	if n != 1 || s.Routes != existing {
+		t.Error("expected a failed validation to leave the existing map untouched")
+	}
+
+	var u struct {
+		Routes *sync.Map `flag:"routes,keytype=ip"`
+	}
+	if err := (&Parser{Args: []string{"-routes=a:b"}}).Decode(&u); err == nil {
+		t.Error("expected an error with an unknown key type")
+	}
+}
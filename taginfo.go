@@ -0,0 +1,111 @@
+package flagstruct
+
+import (
+	"reflect"
+	"strings"
+)
+
+// ParsedTag is the parsed `flag` struct tag of a field, as returned by
+// TagInfo.
+type ParsedTag struct {
+	// Field is the path of the field in the struct (e.g. `Server.Port`).
+	Field string
+	// Name is the name of the flag, including the prefix of its parents.
+	Name string
+	// Short is the short name of the flag, from the `short` option.
+	Short string
+	// Default is the default value of the flag, from the `default` option.
+	Default string
+	// Required holds whether the flag is required.
+	Required bool
+	// Allowed are the allowed values of the flag, nil when unrestricted.
+	Allowed []string
+	// Env are the environment variable names of the flag.
+	Env []string
+	// Sep is the separator of slice and map elements.
+	Sep string
+	// Layout is the layout of time values.
+	Layout string
+	// Redact holds whether the value of the flag is redacted.
+	Redact bool
+}
+
+// TagInfo returns the parsed `flag` struct tag of every tagged field of v,
+// nested structs included, meant for tooling like documentation generators.
+// v must be a struct or a pointer to a struct.
+func TagInfo(v interface{}) ([]ParsedTag, error) {
+	return (&Parser{}).TagInfo(v)
+}
+
+// TagInfo returns the parsed `flag` struct tag of every tagged field of v,
+// following the same rules as the package level TagInfo function, and
+// applying the naming options of the parser.
+func (p *Parser) TagInfo(v interface{}) ([]ParsedTag, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, ErrInvalidType
+	}
+	return p.tagInfo(t, "", "", 0)
+}
+
+func (p *Parser) tagInfo(t reflect.Type, path, prefix string, depth int) ([]ParsedTag, error) {
+	if depth >= p.maxDepth() {
+		return nil, nil
+	}
+	var tags []ParsedTag
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		if ft.PkgPath != "" {
+			continue
+		}
+		nested := false
+		if st := ft.Type; st.Kind() == reflect.Struct || (st.Kind() == reflect.Ptr && st.Elem().Kind() == reflect.Struct) {
+			if st.Kind() == reflect.Ptr {
+				st = st.Elem()
+			}
+			if !isValueStruct(st) && !isCustom(reflect.New(st)) && !p.registered(ft.Type) && !p.registered(st) && tagOption(ft.Tag.Get("flag"), "encoding") == "" {
+				nestedTags, err := p.tagInfo(st, path+ft.Name+".", prefix+tagPrefix(ft.Tag.Get("flag")), depth+1)
+				if err != nil {
+					return nil, err
+				}
+				tags = append(tags, nestedTags...)
+				nested = true
+			}
+		}
+		tag := p.tag(ft, !nested)
+		if tag == "" || (nested && strings.HasPrefix(tag, ",")) {
+			continue
+		}
+		a, err := parseAnnotation(tag)
+		if err != nil {
+			return nil, err
+		}
+		a.name = prefix + a.name
+		if p.NameFunc != nil {
+			a.name = p.NameFunc(ft.Name, a.name)
+		}
+		if v, ok := a.osDefaults[p.goos()]; ok {
+			a.defaultValue = v
+		}
+		var allowed []string
+		if a.hasAllowed {
+			allowed = a.allowed
+		}
+		tags = append(tags, ParsedTag{
+			Field:    path + ft.Name,
+			Name:     a.name,
+			Short:    a.short,
+			Default:  a.defaultValue,
+			Required: a.required,
+			Allowed:  allowed,
+			Env:      a.envNames(),
+			Sep:      a.sep,
+			Layout:   a.layout,
+			Redact:   a.redact,
+		})
+	}
+	return tags, nil
+}
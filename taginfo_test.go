@@ -0,0 +1,53 @@
+package flagstruct
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTagInfo(t *testing.T) {
+	type server struct {
+		Host string `flag:"host,default=localhost"`
+		Port int    `flag:"port,required,env=PORT;APP_PORT"`
+	}
+	type config struct {
+		Server   server        `flag:",prefix=server."`
+		Level    string        `flag:"level,short=l,allowed=debug;info"`
+		Started  time.Time     `flag:"started,layout=2006-01-02"`
+		Tags     []string      `flag:"tags,sep=|"`
+		Password string        `flag:"password,redact"`
+		Timeout  time.Duration `flag:"timeout"`
+		Ignored  string
+	}
+
+	tags, err := TagInfo(&config{})
+	if err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	expected := []ParsedTag{
+		{Field: "Server.Host", Name: "server.host", Default: "localhost", Env: []string{"SERVER_HOST"}, Sep: ";", Layout: time.RFC3339},
+		{Field: "Server.Port", Name: "server.port", Required: true, Env: []string{"PORT", "APP_PORT"}, Sep: ";", Layout: time.RFC3339},
+		{Field: "Level", Name: "level", Short: "l", Allowed: []string{"debug", "info"}, Env: []string{"LEVEL"}, Sep: ";", Layout: time.RFC3339},
+		{Field: "Started", Name: "started", Env: []string{"STARTED"}, Sep: ";", Layout: "2006-01-02"},
+		{Field: "Tags", Name: "tags", Env: []string{"TAGS"}, Sep: "|", Layout: time.RFC3339},
+		{Field: "Password", Name: "password", Env: []string{"PASSWORD"}, Sep: ";", Layout: time.RFC3339, Redact: true},
+		{Field: "Timeout", Name: "timeout", Env: []string{"TIMEOUT"}, Sep: ";", Layout: time.RFC3339},
+	}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("wrong tags expected %+v got %+v", expected, tags)
+	}
+
+	if _, err := TagInfo(config{}); err != nil {
+		t.Errorf("unexpected error with a struct value: %v", err)
+	}
+	if _, err := TagInfo("config"); err != ErrInvalidType {
+		t.Errorf("expected %v, got %v", ErrInvalidType, err)
+	}
+	var invalid struct {
+		Port int `flag:"port,required,default=80"`
+	}
+	if _, err := TagInfo(&invalid); err != ErrInvalidAnnotation {
+		t.Errorf("expected %v, got %v", ErrInvalidAnnotation, err)
+	}
+}
@@ -0,0 +1,21 @@
+package flagstruct
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// render executes flagVal as a text/template against the TemplateData of
+// the parser, failing on keys missing from the data.
+func (p *Parser) render(flagVal string, a *annotation) (string, error) {
+	t, err := template.New(a.name).Option("missingkey=error").Parse(flagVal)
+	if err != nil {
+		return "", fmt.Errorf("flagstruct: could not parse the template of flag '%s': %v", a.name, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, p.TemplateData); err != nil {
+		return "", fmt.Errorf("flagstruct: could not render the template of flag '%s': %v", a.name, err)
+	}
+	return buf.String(), nil
+}
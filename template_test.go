@@ -0,0 +1,46 @@
+package flagstruct
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	type test struct {
+		Greeting string `flag:"greeting,template"`
+		Plain    string `flag:"plain"`
+		Path     string `flag:"path,template,default={{.Home}}/.app"`
+	}
+
+	var ts test
+	p := Parser{
+		Args:         []string{"-greeting=Hello {{.User}}", "-plain={{.User}}"},
+		TemplateData: map[string]string{"User": "gopher", "Home": "/home/gopher"},
+	}
+	if err := p.Decode(&ts); err != nil {
+		t.Errorf("unexpected error with a valid case: %v", err)
+	}
+	expected := test{Greeting: "Hello gopher", Plain: "{{.User}}", Path: "/home/gopher/.app"}
+	if ts != expected {
+		t.Errorf("wrong assignment expected %+v got %+v", expected, ts)
+	}
+
+	p.Args = []string{"-greeting=Hello {{.Name}}"}
+	prefix := "flagstruct: could not render the template of flag 'greeting'"
+	if err := p.Decode(&ts); err == nil || !strings.HasPrefix(err.Error(), prefix) {
+		t.Errorf("wrong error expected %s got %v", prefix, err)
+	}
+
+	var allowed struct {
+		Env string `flag:"env,template,allowed=gopher-dev;gopher-prod"`
+	}
+	p.Args = []string{"-env={{.User}}-dev"}
+	if err := p.Decode(&allowed); err != nil || allowed.Env != "gopher-dev" {
+		t.Errorf("expected the rendered value to be allowed, got %s (%v)", allowed.Env, err)
+	}
+
+	p.Args = []string{"-greeting=Hello {{.User"}
+	if err := p.Decode(&ts); err == nil {
+		t.Error("expected error for a malformed template")
+	}
+}
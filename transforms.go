@@ -0,0 +1,57 @@
+package flagstruct
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// builtinTransforms holds the transforms available to every parser, unless
+// a transform is registered under the same name.
+var builtinTransforms = map[string]func(string) string{
+	"trim":  strings.TrimSpace,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"snake": snake,
+}
+
+// RegisterTransform registers a named transform, applied to the resolved
+// value of the flags annotated with ",pipe=<name>|<name>..." (in the order
+// they are listed) before decoding it.
+//
+//	p.RegisterTransform("nodash", func(v string) string {
+//		return strings.Replace(v, "-", "", -1)
+//	})
+func (p *Parser) RegisterTransform(name string, fn func(string) string) {
+	if p.transforms == nil {
+		p.transforms = make(map[string]func(string) string)
+	}
+	p.transforms[name] = fn
+}
+
+// transform applies the transforms of the annotation pipeline to flagVal.
+func (p *Parser) transform(flagVal string, a *annotation) (string, error) {
+	for _, name := range a.pipe {
+		fn, ok := p.transforms[name]
+		if !ok {
+			fn, ok = builtinTransforms[name]
+		}
+		if !ok {
+			return "", fmt.Errorf("flagstruct: no transform registered as `%s` for flag '%s'", name, a.name)
+		}
+		flagVal = fn(flagVal)
+	}
+	return flagVal, nil
+}
+
+// snake returns value in snake case (e.g. `Hello World` and `helloWorld`
+// become `hello_world`).
+func snake(value string) string {
+	var words []string
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words = append(words, splitWords(field)...)
+	}
+	return strings.ToLower(strings.Join(words, "_"))
+}
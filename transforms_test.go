@@ -0,0 +1,51 @@
+package flagstruct
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSnake(t *testing.T) {
+	tests := map[string]string{
+		"hello":           "hello",
+		"Hello World":     "hello_world",
+		"helloWorld":      "hello_world",
+		"HTTPServer-name": "http_server_name",
+		"  max  retries ": "max_retries",
+		"already_snake":   "already_snake",
+	}
+
+	for value, expected := range tests {
+		if result := snake(value); result != expected {
+			t.Errorf("wrong snake case of `%s` expected `%s` got `%s`", value, expected, result)
+		}
+	}
+}
+
+func TestDecodePipe(t *testing.T) {
+	var s struct {
+		Name   string `flag:"name,pipe=trim|snake"`
+		Level  string `flag:"level,pipe=trim|upper,allowed=DEBUG;INFO"`
+		Region string `flag:"region,pipe=nodash|lower"`
+	}
+
+	p := Parser{Args: []string{"-name=  My Service ", "-level= info", "-region=EU-West-1"}}
+	p.RegisterTransform("nodash", func(v string) string {
+		return strings.Replace(v, "-", "", -1)
+	})
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if s.Name != "my_service" || s.Level != "INFO" || s.Region != "euwest1" {
+		t.Errorf("wrong values decoded: %+v", s)
+	}
+
+	var u struct {
+		Name string `flag:"name,pipe=trim|reverse"`
+	}
+	err := (&Parser{Args: []string{"-name=abc"}}).Decode(&u)
+	expected := "flagstruct: no transform registered as `reverse` for flag 'name'"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected `%s` got `%v`", expected, err)
+	}
+}
@@ -0,0 +1,136 @@
+package flagstruct
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	jsonNumberType = reflect.TypeOf(json.Number(""))
+	ratType        = reflect.TypeOf(big.Rat{})
+	monthType      = reflect.TypeOf(time.Month(0))
+	weekdayType    = reflect.TypeOf(time.Weekday(0))
+	urlValuesType  = reflect.TypeOf(url.Values(nil))
+	ipType         = reflect.TypeOf(net.IP(nil))
+	templateType   = reflect.TypeOf(template.Template{})
+
+	jsonNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+	// versionedTypes holds the decode functions of the well known types only
+	// available on some Go versions, registered by their own files.
+	versionedTypes = make(map[reflect.Type]func(f *reflect.Value, flagVal string) error)
+)
+
+// decodeKnown decodes flagVal into fields of well known standard library
+// types, reporting whether f is one of them.
+func decodeKnown(f *reflect.Value, flagVal string) (bool, error) {
+	switch f.Type() {
+	case jsonNumberType:
+		if !jsonNumberPattern.MatchString(flagVal) {
+			return true, fmt.Errorf("`%s` is not a valid number", flagVal)
+		}
+		f.SetString(flagVal)
+		return true, nil
+	case ratType, reflect.PtrTo(ratType):
+		r, ok := new(big.Rat).SetString(flagVal)
+		if !ok {
+			return true, fmt.Errorf("`%s` is not a valid fraction or decimal", flagVal)
+		}
+		if f.Kind() == reflect.Ptr {
+			f.Set(reflect.ValueOf(r))
+		} else {
+			f.Set(reflect.ValueOf(r).Elem())
+		}
+		return true, nil
+	case reflect.PtrTo(templateType):
+		t, err := template.New("").Parse(flagVal)
+		if err != nil {
+			return true, err
+		}
+		f.Set(reflect.ValueOf(t))
+		return true, nil
+	case ipType:
+		ip := net.ParseIP(flagVal)
+		if ip == nil {
+			return true, fmt.Errorf("`%s` is not a valid IP address", flagVal)
+		}
+		f.Set(reflect.ValueOf(ip))
+		return true, nil
+	case monthType:
+		return true, decodeCalendar(f, flagVal, int(time.January), int(time.December), func(n int) string {
+			return time.Month(n).String()
+		})
+	case weekdayType:
+		return true, decodeCalendar(f, flagVal, int(time.Sunday), int(time.Saturday), func(n int) string {
+			return time.Weekday(n).String()
+		})
+	}
+	if decode, ok := versionedTypes[f.Type()]; ok {
+		return true, decode(f, flagVal)
+	}
+	return false, nil
+}
+
+// decodeCalendar decodes either the English name (case insensitive) or the
+// number of a month or a weekday, within the [first, last] range.
+func decodeCalendar(f *reflect.Value, flagVal string, first, last int, name func(int) string) error {
+	if n, err := strconv.Atoi(flagVal); err == nil {
+		if n < first || n > last {
+			return fmt.Errorf("`%s` is out of range [%d, %d]", flagVal, first, last)
+		}
+		f.SetInt(int64(n))
+		return nil
+	}
+	for n := first; n <= last; n++ {
+		if strings.EqualFold(flagVal, name(n)) {
+			f.SetInt(int64(n))
+			return nil
+		}
+	}
+	return fmt.Errorf("`%s` is not a valid %s", flagVal, strings.ToLower(f.Type().Name()))
+}
+
+// decodeQuery decodes the query string flagVal (e.g. `a=1&b=2&a=3`) into the
+// url.Values f, keeping every value of repeated keys.
+func decodeQuery(f *reflect.Value, flagVal string) error {
+	values, err := url.ParseQuery(flagVal)
+	if err != nil {
+		return fmt.Errorf("`%s` is not a valid query string: %w", flagVal, err)
+	}
+	f.Set(reflect.ValueOf(values))
+	return nil
+}
+
+// RegisterType registers the function decoding the fields (and slice
+// elements) of type t, taking precedence over the built-in decoding. It is
+// meant for third-party types that can not implement Decoder.
+//
+//	p.RegisterType(reflect.TypeOf(uuid.UUID{}), func(dst reflect.Value, raw string) error {
+//		id, err := uuid.Parse(raw)
+//		if err != nil {
+//			return err
+//		}
+//		dst.Set(reflect.ValueOf(id))
+//		return nil
+//	})
+func (p *Parser) RegisterType(t reflect.Type, fn func(dst reflect.Value, raw string) error) {
+	if p.types == nil {
+		p.types = make(map[reflect.Type]func(dst reflect.Value, raw string) error)
+	}
+	p.types[t] = fn
+}
+
+// registered reports whether a decode function was registered for t.
+func (p *Parser) registered(t reflect.Type) bool {
+	_, ok := p.types[t]
+	return ok
+}
@@ -0,0 +1,313 @@
+package flagstruct
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestDecodeJSONNumber(t *testing.T) {
+	type test struct {
+		value string
+		err   bool
+	}
+
+	tests := []*test{
+		{value: "42"},
+		{value: "-0.5"},
+		{value: "1.5e-3"},
+		{value: "12345678901234567890123"},
+		{value: "abc", err: true},
+		{value: "01", err: true},
+		{value: "1.", err: true},
+		{value: "0x10", err: true},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			Number json.Number `flag:"number"`
+		}
+		err := (&Parser{Args: []string{"-number=" + ts.value}}).Decode(&s)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+		if !ts.err && s.Number.String() != ts.value {
+			t.Errorf("case #%d: wrong number expected %s got %s", i, ts.value, s.Number)
+		}
+	}
+}
+
+func TestDecodeRat(t *testing.T) {
+	type test struct {
+		value    string
+		expected *big.Rat
+		err      bool
+	}
+
+	tests := []*test{
+		{value: "3/4", expected: big.NewRat(3, 4)},
+		{value: "0.75", expected: big.NewRat(3, 4)},
+		{value: "-6/8", expected: big.NewRat(-3, 4)},
+		{value: "3/0", err: true},
+		{value: "a/4", err: true},
+		{value: "three quarters", err: true},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			Ratio *big.Rat `flag:"ratio"`
+			Value big.Rat  `flag:"value"`
+		}
+		err := (&Parser{Args: []string{"-ratio=" + ts.value, "-value=" + ts.value}}).Decode(&s)
+		if (err != nil) != ts.err {
+			t.Errorf("case #%d: unexpected error result %v", i, err)
+		}
+		if ts.err {
+			continue
+		}
+		if s.Ratio == nil || s.Ratio.Cmp(ts.expected) != 0 || s.Value.Cmp(ts.expected) != 0 {
+			t.Errorf("case #%d: wrong ratio expected %v got %v and %v", i, ts.expected, s.Ratio, &s.Value)
+		}
+	}
+}
+
+func TestDecodeRunes(t *testing.T) {
+	type test struct {
+		value    string
+		expected []rune
+	}
+
+	tests := []*test{
+		{value: "abc", expected: []rune{'a', 'b', 'c'}},
+		{value: "a;b", expected: []rune{'a', ';', 'b'}},
+		{value: "héllo", expected: []rune{'h', 'é', 'l', 'l', 'o'}},
+		{value: "日本語", expected: []rune{'日', '本', '語'}},
+		{value: "👍!", expected: []rune{'👍', '!'}},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			Chars []rune `flag:"chars,runes"`
+		}
+		if err := (&Parser{Args: []string{"-chars=" + ts.value}}).Decode(&s); err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if !reflect.DeepEqual(s.Chars, ts.expected) {
+			t.Errorf("case #%d: wrong runes expected %q got %q", i, ts.expected, s.Chars)
+		}
+	}
+
+	var ids struct {
+		IDs []int32 `flag:"ids"`
+	}
+	if err := (&Parser{Args: []string{"-ids=1;2;3"}}).Decode(&ids); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(ids.IDs, []int32{1, 2, 3}) {
+		t.Errorf("wrong []int32 expected [1 2 3] got %v", ids.IDs)
+	}
+
+	var invalid struct {
+		Chars string `flag:"chars,runes"`
+	}
+	expected := "flagstruct: option `runes` of flag 'chars' requires a []rune field"
+	if err := (&Parser{Args: []string{"-chars=abc"}}).Decode(&invalid); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected `%s` got `%v`", expected, err)
+	}
+}
+
+func TestRegisterType(t *testing.T) {
+	var s struct {
+		Endpoint url.URL   `flag:"endpoint"`
+		Mirrors  []url.URL `flag:"mirrors"`
+		Proxy    *url.URL  `flag:"proxy"`
+	}
+
+	p := Parser{Args: []string{
+		"-endpoint=https://example.com/api",
+		"-mirrors=https://a.example.com;https://b.example.com",
+		"-proxy=http://proxy:3128",
+	}}
+	p.RegisterType(reflect.TypeOf(url.URL{}), func(dst reflect.Value, raw string) error {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(*u))
+		return nil
+	})
+	p.RegisterType(reflect.TypeOf(&url.URL{}), func(dst reflect.Value, raw string) error {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(u))
+		return nil
+	})
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if s.Endpoint.Host != "example.com" || s.Endpoint.Path != "/api" {
+		t.Errorf("wrong endpoint got %v", s.Endpoint.String())
+	}
+	if len(s.Mirrors) != 2 || s.Mirrors[1].Host != "b.example.com" {
+		t.Errorf("wrong mirrors got %v", s.Mirrors)
+	}
+	if s.Proxy == nil || s.Proxy.Host != "proxy:3128" {
+		t.Errorf("wrong proxy got %v", s.Proxy)
+	}
+
+	p.Args = []string{"-endpoint=%zz"}
+	if err := p.Decode(&s); err == nil {
+		t.Error("expected error of the registered function")
+	}
+}
+
+func TestDecodeCalendar(t *testing.T) {
+	type test struct {
+		args    []string
+		month   time.Month
+		day     time.Weekday
+		wantErr bool
+	}
+
+	tests := []*test{
+		{args: []string{"-month=March", "-day=Monday"}, month: time.March, day: time.Monday},
+		{args: []string{"-month=december", "-day=SUNDAY"}, month: time.December, day: time.Sunday},
+		{args: []string{"-month=1", "-day=6"}, month: time.January, day: time.Saturday},
+		{args: []string{"-month=Mar"}, wantErr: true},
+		{args: []string{"-month=13"}, wantErr: true},
+		{args: []string{"-day=Funday"}, wantErr: true},
+		{args: []string{"-day=7"}, wantErr: true},
+	}
+
+	for i, ts := range tests {
+		var s struct {
+			Month time.Month   `flag:"month"`
+			Day   time.Weekday `flag:"day"`
+		}
+		err := (&Parser{Args: ts.args}).Decode(&s)
+		if ts.wantErr {
+			if err == nil {
+				t.Errorf("case #%d: expected an error, got %+v", i, s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case #%d: unexpected error %v", i, err)
+		}
+		if s.Month != ts.month || s.Day != ts.day {
+			t.Errorf("case #%d: wrong values expected %v %v got %v %v", i, ts.month, ts.day, s.Month, s.Day)
+		}
+	}
+
+	var days struct {
+		Days []time.Weekday `flag:"days"`
+	}
+	if err := (&Parser{Args: []string{"-days=saturday;0"}}).Decode(&days); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if !reflect.DeepEqual(days.Days, []time.Weekday{time.Saturday, time.Sunday}) {
+		t.Errorf("wrong days decoded: %v", days.Days)
+	}
+}
+
+func TestDecodeQuery(t *testing.T) {
+	var s struct {
+		Query url.Values `flag:"query"`
+	}
+
+	p := Parser{Args: []string{"-query=a=1&b=2&a=3&name=John+Doe&path=%2Fvar%2Flog"}}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	expected := url.Values{
+		"a":    {"1", "3"},
+		"b":    {"2"},
+		"name": {"John Doe"},
+		"path": {"/var/log"},
+	}
+	if !reflect.DeepEqual(s.Query, expected) {
+		t.Errorf("wrong query expected %v got %v", expected, s.Query)
+	}
+
+	p.Args = []string{"-query=a=%zz"}
+	err := p.Decode(&s)
+	var escapeErr url.EscapeError
+	if !errors.As(err, &escapeErr) {
+		t.Errorf("expected a wrapped url.EscapeError, got %v", err)
+	}
+}
+
+func TestDecodeIP(t *testing.T) {
+	var s struct {
+		Addr  net.IP   `flag:"addr"`
+		Allow []net.IP `flag:"allow"`
+	}
+
+	p := Parser{Args: []string{"-addr=2001:db8::1", "-allow=10.0.0.1; 10.0.0.2;::1"}}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if !s.Addr.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("wrong address %v", s.Addr)
+	}
+	expected := []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), net.ParseIP("::1")}
+	if !reflect.DeepEqual(s.Allow, expected) {
+		t.Errorf("wrong allowlist expected %v got %v", expected, s.Allow)
+	}
+
+	var rejected []string
+	p = Parser{Args: []string{"-allow=10.0.0.1;10.0.0.300;10.0.0.3"}, Rejected: func(name, raw string, err error) {
+		rejected = append(rejected, raw)
+	}}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error in lenient mode: %v", err)
+	}
+	if len(s.Allow) != 2 || !s.Allow[1].Equal(net.ParseIP("10.0.0.3")) || !reflect.DeepEqual(rejected, []string{"10.0.0.300"}) {
+		t.Errorf("expected the invalid address to be dropped, got %v (rejected %v)", s.Allow, rejected)
+	}
+
+	p.Strict = true
+	if err := p.Decode(&s); err == nil {
+		t.Error("expected an error with an invalid address in strict mode")
+	}
+	p.Args = []string{"-addr=localhost"}
+	if err := p.Decode(&s); err == nil {
+		t.Error("expected an error with an invalid address")
+	}
+}
+
+func TestDecodeTemplate(t *testing.T) {
+	var s struct {
+		Greeting *template.Template `flag:"greeting"`
+		Unset    *template.Template `flag:"unset"`
+	}
+
+	p := Parser{Args: []string{"-greeting=Hello {{.Name}}{{if .Admin}} (admin){{end}}"}}
+	if err := p.Decode(&s); err != nil {
+		t.Fatalf("unexpected error with a valid case: %v", err)
+	}
+	if s.Unset != nil {
+		t.Error("expected the template of a missing flag to stay nil")
+	}
+	var b strings.Builder
+	if err := s.Greeting.Execute(&b, map[string]interface{}{"Name": "gopher", "Admin": true}); err != nil {
+		t.Fatalf("unexpected error executing the template: %v", err)
+	}
+	if b.String() != "Hello gopher (admin)" {
+		t.Errorf("wrong rendering %q", b.String())
+	}
+
+	p.Args = []string{"-greeting=Hello {{.Name"}
+	if err := p.Decode(&s); err == nil || !strings.Contains(err.Error(), "template:") {
+		t.Errorf("expected a template parse error, got %v", err)
+	}
+}
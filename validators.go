@@ -0,0 +1,31 @@
+package flagstruct
+
+import "fmt"
+
+// RegisterValidator registers a named validator, run against the resolved
+// value of the flags annotated with ",validate=<name>" before decoding it.
+//
+//	p.RegisterValidator("email", func(v string) error {
+//		if _, err := mail.ParseAddress(v); err != nil {
+//			return err
+//		}
+//		return nil
+//	})
+func (p *Parser) RegisterValidator(name string, fn func(string) error) {
+	if p.validators == nil {
+		p.validators = make(map[string]func(string) error)
+	}
+	p.validators[name] = fn
+}
+
+// validate runs the validator of the annotation against flagVal.
+func (p *Parser) validate(flagVal string, a *annotation) error {
+	validate, ok := p.validators[a.validate]
+	if !ok {
+		return fmt.Errorf("flagstruct: no validator registered as `%s` for flag '%s'", a.validate, a.name)
+	}
+	if err := validate(flagVal); err != nil {
+		return fmt.Errorf("flagstruct: the value `%s` of flag '%s' is not valid: %v", flagVal, a.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package flagstruct
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegisterValidator(t *testing.T) {
+	type test struct {
+		Email string `flag:"email,validate=email"`
+	}
+
+	type decode struct {
+		args []string
+		err  string
+	}
+	tests := []*decode{
+		{args: []string{"-email=user@example.com"}},
+		{args: []string{}},
+		{
+			args: []string{"-email=example.com"},
+			err:  "flagstruct: the value `example.com` of flag 'email' is not valid: missing @",
+		},
+	}
+
+	p := Parser{}
+	p.RegisterValidator("email", func(v string) error {
+		if !strings.Contains(v, "@") {
+			return errors.New("missing @")
+		}
+		return nil
+	})
+	for i, ts := range tests {
+		var result test
+		p.Args = ts.args
+		err := p.Decode(&result)
+		if (ts.err == "" && err != nil) || (ts.err != "" && (err == nil || err.Error() != ts.err)) {
+			t.Errorf("case #%d: wrong error expected %s got %v", i, ts.err, err)
+		}
+	}
+
+	// unregistered validators fail even when the flag is not provided
+	var unknown struct {
+		Name string `flag:"name,validate=missing"`
+	}
+	p.Args = []string{}
+	expected := "flagstruct: malformed annotation, no validator registered as `missing` for flag 'name'"
+	if err := p.Decode(&unknown); err == nil || err.Error() != expected {
+		t.Errorf("wrong error expected %s got %v", expected, err)
+	}
+}